@@ -4,7 +4,11 @@ go 1.16
 
 require (
 	github.com/golang/protobuf v1.5.2
+	golang.org/x/net v0.7.0
+	golang.org/x/time v0.0.0-20220210224613-90d013bbcef8
+	k8s.io/api v0.26.2
 	k8s.io/apimachinery v0.26.2
 	k8s.io/client-go v0.26.2
 	k8s.io/helm v2.17.0+incompatible
+	sigs.k8s.io/yaml v1.3.0
 )
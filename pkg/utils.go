@@ -3,24 +3,58 @@ package utils
 import (
 	"bytes"
 	"compress/gzip"
+	"container/list"
 	ctx "context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
+	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	cpb "k8s.io/helm/pkg/proto/hapi/chart"
 	rspb "k8s.io/helm/pkg/proto/hapi/release"
+	sigsyaml "sigs.k8s.io/yaml"
 
 	// Enable usage of the following providers
 	_ "k8s.io/client-go/plugin/pkg/client/auth/azure"
@@ -32,6 +66,97 @@ type ListOptions struct {
 	ReleaseName     string
 	TillerNamespace string
 	TillerLabel     string
+	// StorageDriver overrides storage auto-detection (secret(s)/configmap(s), as in HELM_DRIVER),
+	// skipping the tiller pods lookup when the storage type is already known
+	StorageDriver string
+	// StorageTypes queries all provided storage types (configmaps/secrets) and merges the results
+	StorageTypes []string
+	// AllStorageTypes queries both configmaps and secrets and merges the results
+	AllStorageTypes bool
+	// OnlyWithTests returns only releases defining test hooks
+	OnlyWithTests bool
+	// ExtractManifestLabel sets ReleaseData.ManifestLabel to the value of this label in the release manifest
+	ExtractManifestLabel string
+	// ChartSource returns only releases with a chart source containing this string
+	ChartSource string
+	// IncludeValues populates ReleaseData.ConfigValues, which requires parsing the values of each release
+	IncludeValues bool
+	// FieldSelector is passed to the kubernetes list calls (e.g. metadata.name=<name>.v<revision>)
+	FieldSelector string
+	// MaxResults stops decoding releases after this many results, returning ErrTruncated if more remain
+	MaxResults int
+	// MaxReleases is like MaxResults, but sorts the releases by name and revision before decoding them,
+	// so that the same first releases are returned on each call
+	MaxReleases int
+	// IncludeManifest populates ReleaseData.Manifest, defaults to true when nil
+	IncludeManifest *bool
+	// ModifiedByKey is the label or annotation of the tiller resources holding the user who modified a release,
+	// populating ReleaseData.ModifiedBy
+	ModifiedByKey string
+	// ModifiedBy returns only releases modified by this user, according to ModifiedByKey
+	ModifiedBy string
+	// ReleaseDataKey is the data key holding the encoded release in the tiller resources, defaults to "release".
+	// When the key is missing, the first data key starting with "release" (e.g. release.v1.<name>.v<revision>) is used
+	ReleaseDataKey string
+	// ResourceVersion is the resource version WatchReleases starts from (e.g. the last seen one, to resume after a disconnect)
+	ResourceVersion string
+	// OnDecodeError is called when a release can not be decoded. Returning an error aborts the operation,
+	// returning nil skips the release
+	OnDecodeError func(err error, objectName string) error
+	// Metrics is called with the duration of each stage of listing releases ("list", "decode" and "filter")
+	Metrics func(stage string, d time.Duration)
+	// DryRun reports the changes of an operation (e.g. PruneHistory) without applying them
+	DryRun bool
+	// SkipManifest leaves ReleaseData.Manifest empty, for callers only needing the release metadata
+	// (values are only decoded with IncludeValues). It is a shorthand for IncludeManifest=false,
+	// ignored when IncludeManifest is set
+	SkipManifest bool
+}
+
+// ErrTruncated is returned with the partial list of releases when ListOptions.MaxResults is reached
+var ErrTruncated = errors.New("releases list truncated")
+
+// Classes of the kubernetes API errors, to be checked with errors.Is. ErrNotFound is also
+// returned by the operations which do not find the requested release or revision
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrForbidden   = errors.New("access denied")
+	ErrUnreachable = errors.New("cluster unreachable")
+)
+
+// apiError is a kubernetes API error classified as ErrNotFound, ErrForbidden or ErrUnreachable
+type apiError struct {
+	class error
+	err   error
+}
+
+func (e *apiError) Error() string {
+	return e.err.Error()
+}
+
+func (e *apiError) Unwrap() error {
+	return e.err
+}
+
+func (e *apiError) Is(target error) bool {
+	return target == e.class
+}
+
+// wrapAPIError classifies a kubernetes API error, keeping the original error available to errors.As and apierrors
+func wrapAPIError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var netErr net.Error
+	switch {
+	case apierrors.IsNotFound(err):
+		return &apiError{ErrNotFound, err}
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return &apiError{ErrForbidden, err}
+	case errors.As(err, &netErr):
+		return &apiError{ErrUnreachable, err}
+	}
+	return err
 }
 
 type ReleaseData struct {
@@ -43,189 +168,3258 @@ type ReleaseData struct {
 	Namespace string
 	Time      time.Time
 	Manifest  string
+	Notes     string
+	HasTests  bool
+	// Description is the description of the last operation on the release (e.g. "Upgrade complete")
+	Description string
+	// Chart metadata
+	ChartVersion  string
+	AppVersion    string
+	ChartIcon     string
+	ChartHome     string
+	ChartSources  []string
+	ChartKeywords []string
+	// ManifestLabel is populated according to ListOptions.ExtractManifestLabel
+	ManifestLabel string
+	// StorageCreationTime is the creation timestamp of the storage resource (configmap/secret)
+	StorageCreationTime time.Time
+	// ModifiedBy is the user who modified the release, populated according to ListOptions.ModifiedByKey
+	ModifiedBy string
+	// ConfigValues are the user-supplied values, populated according to ListOptions.IncludeValues
+	ConfigValues map[string]interface{}
+	// Incomplete is set when the release has no info or chart metadata (e.g. partially written or legacy releases)
+	Incomplete bool
+}
+
+// storageObject holds the metadata and the encoded release of a tiller resource (configmap/secret)
+type storageObject struct {
+	metav1.ObjectMeta
+	itemReleaseData string
+}
+
+// getReleaseData returns a decoded structed release data of a tiller resource (configmap/secret)
+func (so storageObject) getReleaseData(includeValues bool) (*ReleaseData, error) {
+	releaseData, err := getReleaseData(so.itemReleaseData, includeValues)
+	if err != nil {
+		return nil, err
+	}
+	releaseData.StorageCreationTime = so.CreationTimestamp.Time
+	return releaseData, nil
+}
+
+// Client holds a kubernetes ClientSet shared by all operations
+type Client struct {
+	ClientSet kubernetes.Interface
+	// DynamicClient is used by operations reading the resources of a release
+	DynamicClient dynamic.Interface
+	// Cache, if set, holds the decoded releases to skip decoding tiller resources which did not change
+	Cache ReleaseCache
+}
+
+// ReleaseCache holds decoded releases by the UID and resource version of their tiller resource (configmap/secret).
+// Releases decoded with values are cached under the UID suffixed with "+values"
+type ReleaseCache interface {
+	Get(uid, resourceVersion string) (*ReleaseData, bool)
+	Set(uid, resourceVersion string, releaseData *ReleaseData)
+}
+
+// DefaultReleaseCacheSize is the number of entries kept by NewMemoryReleaseCache
+const DefaultReleaseCacheSize = 1000
+
+// memoryReleaseCache is a ReleaseCache keeping the latest resource version of the most recently used
+// tiller resources in memory, so entries of deleted revisions are eventually evicted
+type memoryReleaseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	recent     *list.List
+}
+
+type memoryReleaseCacheEntry struct {
+	uid             string
+	resourceVersion string
+	releaseData     *ReleaseData
+}
+
+// NewMemoryReleaseCache returns a ReleaseCache keeping the latest resource version of at most
+// DefaultReleaseCacheSize tiller resources in memory, evicting the least recently used ones
+func NewMemoryReleaseCache() ReleaseCache {
+	return NewMemoryReleaseCacheWithSize(DefaultReleaseCacheSize)
+}
+
+// NewMemoryReleaseCacheWithSize returns a ReleaseCache keeping the latest resource version of at most
+// maxEntries tiller resources in memory, evicting the least recently used ones
+func NewMemoryReleaseCacheWithSize(maxEntries int) ReleaseCache {
+	if maxEntries < 1 {
+		maxEntries = DefaultReleaseCacheSize
+	}
+	return &memoryReleaseCache{maxEntries: maxEntries, entries: make(map[string]*list.Element), recent: list.New()}
+}
+
+// Get returns the decoded release of a tiller resource if it is cached with the provided resource version
+func (m *memoryReleaseCache) Get(uid, resourceVersion string) (*ReleaseData, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	element, ok := m.entries[uid]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*memoryReleaseCacheEntry)
+	if entry.resourceVersion != resourceVersion {
+		return nil, false
+	}
+	m.recent.MoveToFront(element)
+	return entry.releaseData, true
+}
+
+// Set caches the decoded release of a tiller resource, replacing the ones of previous resource versions
+func (m *memoryReleaseCache) Set(uid, resourceVersion string, releaseData *ReleaseData) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if element, ok := m.entries[uid]; ok {
+		entry := element.Value.(*memoryReleaseCacheEntry)
+		entry.resourceVersion, entry.releaseData = resourceVersion, releaseData
+		m.recent.MoveToFront(element)
+		return
+	}
+	m.entries[uid] = m.recent.PushFront(&memoryReleaseCacheEntry{uid, resourceVersion, releaseData})
+	if m.recent.Len() > m.maxEntries {
+		oldest := m.recent.Back()
+		m.recent.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryReleaseCacheEntry).uid)
+	}
+}
+
+// decodeStorageObject returns a decoded structed release data of a tiller resource (configmap/secret), using c.Cache if set
+func (c *Client) decodeStorageObject(item storageObject, includeValues bool) (*ReleaseData, error) {
+	if c.Cache == nil || item.UID == "" || item.ResourceVersion == "" {
+		return item.getReleaseData(includeValues)
+	}
+	// releases decoded with and without values are cached as separate entries
+	key := string(item.UID)
+	if includeValues {
+		key += "+values"
+	}
+	// the cache holds its own copy, so callers may modify the returned release data
+	if cached, ok := c.Cache.Get(key, item.ResourceVersion); ok {
+		return copyReleaseData(cached), nil
+	}
+	releaseData, err := item.getReleaseData(includeValues)
+	if err != nil {
+		return nil, err
+	}
+	c.Cache.Set(key, item.ResourceVersion, copyReleaseData(releaseData))
+	return releaseData, nil
+}
+
+// copyReleaseData returns a deep copy of a release data
+func copyReleaseData(releaseData *ReleaseData) *ReleaseData {
+	copied := *releaseData
+	if releaseData.ChartSources != nil {
+		copied.ChartSources = append([]string(nil), releaseData.ChartSources...)
+	}
+	if releaseData.ChartKeywords != nil {
+		copied.ChartKeywords = append([]string(nil), releaseData.ChartKeywords...)
+	}
+	if releaseData.ConfigValues != nil {
+		copied.ConfigValues = copyValue(releaseData.ConfigValues).(map[string]interface{})
+	}
+	return &copied
+}
+
+// copyValue returns a deep copy of a value parsed from YAML
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			copied[k] = copyValue(value)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, value := range v {
+			copied[i] = copyValue(value)
+		}
+		return copied
+	}
+	return v
+}
+
+// NewClient returns a Client using a provided kubernetes ClientSet
+func NewClient(clientSet kubernetes.Interface) *Client {
+	return &Client{ClientSet: clientSet}
+}
+
+// NewClientWithDynamic returns a Client using a provided kubernetes ClientSet and dynamic client.
+// The dynamic client is required by the operations reading the resources of a release
+// and must be built from a JSON config (e.g. with dynamic.NewForConfig)
+func NewClientWithDynamic(clientSet kubernetes.Interface, dynamicClient dynamic.Interface) *Client {
+	return &Client{ClientSet: clientSet, DynamicClient: dynamicClient}
+}
+
+// NewClientWithKubeConfig returns a Client using a provided kubeconfig file and context
+func NewClientWithKubeConfig(kubeConfigFile, context string) *Client {
+	clientSet, dynamicClient := GetClientSetAndDynamicClientWithKubeConfig(kubeConfigFile, context)
+	return &Client{ClientSet: clientSet, DynamicClient: dynamicClient}
+}
+
+// NewClientWithOptions returns a Client using provided options (kubeconfig, context and overrides).
+// All operations of the Client, including the tiller storage detection, use the same cluster
+func NewClientWithOptions(o ClientSetOptions) (*Client, error) {
+	config, err := buildRestConfig(o)
+	if err != nil {
+		return nil, err
+	}
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{ClientSet: clientSet, DynamicClient: dynamicClient}, nil
+}
+
+// getLiveObject returns the live resource of a manifest object, using defaultNamespace for namespaced resources without a namespace
+func (c *Client) getLiveObject(mapper meta.RESTMapper, object unstructured.Unstructured, defaultNamespace string) (*unstructured.Unstructured, error) {
+	if c.DynamicClient == nil {
+		return nil, fmt.Errorf("a dynamic client is required to read release resources")
+	}
+	gvk := object.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return c.DynamicClient.Resource(mapping.Resource).Get(ctx.Background(), object.GetName(), metav1.GetOptions{})
+	}
+	namespace := object.GetNamespace()
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	return c.DynamicClient.Resource(mapping.Resource).Namespace(namespace).Get(ctx.Background(), object.GetName(), metav1.GetOptions{})
+}
+
+// newRESTMapper returns a RESTMapper backed by the cached discovery of the ClientSet
+func (c *Client) newRESTMapper() meta.RESTMapper {
+	return restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(c.ClientSet.Discovery()))
+}
+
+// ListReleases lists all releases according to provided options
+func ListReleases(o ListOptions) ([]ReleaseData, error) {
+	return ListReleasesWithKubeConfig(o, "", "")
+}
+
+// ListReleasesWithKubeConfig lists all releases according to provided options
+func ListReleasesWithKubeConfig(o ListOptions, kubeConfigFile, context string) ([]ReleaseData, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).ListReleases(o)
+}
+
+// ListReleases lists all releases according to provided options
+func (c *Client) ListReleases(o ListOptions) ([]ReleaseData, error) {
+	var releasesData []ReleaseData
+	err := c.forEachRelease(o, func(releaseData *ReleaseData) bool {
+		releasesData = append(releasesData, *releaseData)
+		return true
+	})
+	if err == ErrTruncated {
+		return releasesData, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return releasesData, nil
+}
+
+// WatchReleases watches the tiller resources (configmaps/secrets) according to provided options,
+// starting from ListOptions.ResourceVersion. Events hold *corev1.Secret or *corev1.ConfigMap objects
+func WatchReleases(o ListOptions) (watch.Interface, error) {
+	return WatchReleasesWithKubeConfig(o, "", "")
+}
+
+// WatchReleasesWithKubeConfig watches the tiller resources (configmaps/secrets) according to provided options,
+// starting from ListOptions.ResourceVersion. Events hold *corev1.Secret or *corev1.ConfigMap objects
+func WatchReleasesWithKubeConfig(o ListOptions, kubeConfigFile, context string) (watch.Interface, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).WatchReleases(o)
+}
+
+// WatchReleases watches the tiller resources (configmaps/secrets) according to provided options,
+// starting from ListOptions.ResourceVersion. Events hold *corev1.Secret or *corev1.ConfigMap objects
+func (c *Client) WatchReleases(o ListOptions) (watch.Interface, error) {
+	if err := ValidateListOptions(o); err != nil {
+		return nil, err
+	}
+	if o.TillerNamespace == "" {
+		o.TillerNamespace = "kube-system"
+	}
+	if o.TillerLabel == "" {
+		o.TillerLabel = "OWNER=TILLER"
+	}
+	if o.ReleaseName != "" {
+		o.TillerLabel += fmt.Sprintf(",NAME=%s", o.ReleaseName)
+	}
+	storageTypes, err := c.getStorageTypes(o)
+	if err != nil {
+		return nil, err
+	}
+	if len(storageTypes) != 1 {
+		return nil, fmt.Errorf("watching releases requires a single storage type, got %d", len(storageTypes))
+	}
+	listOptions := metav1.ListOptions{
+		LabelSelector:   o.TillerLabel,
+		FieldSelector:   o.FieldSelector,
+		ResourceVersion: o.ResourceVersion,
+	}
+	var w watch.Interface
+	if storageTypes[0] == "secrets" {
+		w, err = c.ClientSet.CoreV1().Secrets(o.TillerNamespace).Watch(ctx.Background(), listOptions)
+	} else {
+		w, err = c.ClientSet.CoreV1().ConfigMaps(o.TillerNamespace).Watch(ctx.Background(), listOptions)
+	}
+	if err != nil {
+		return nil, wrapAPIError(err)
+	}
+	return w, nil
+}
+
+// IsDeployed returns true if a release is deployed in a provided namespace
+func IsDeployed(name, namespace string, o ListOptions) (bool, error) {
+	return IsDeployedWithKubeConfig(name, namespace, o, "", "")
+}
+
+// IsDeployedWithKubeConfig returns true if a release is deployed in a provided namespace
+func IsDeployedWithKubeConfig(name, namespace string, o ListOptions, kubeConfigFile, context string) (bool, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).IsDeployed(name, namespace, o)
+}
+
+// IsDeployed returns true if a release is deployed in a provided namespace
+func (c *Client) IsDeployed(name, namespace string, o ListOptions) (bool, error) {
+	o.ReleaseName = name
+	deployed := false
+	err := c.forEachRelease(o, func(releaseData *ReleaseData) bool {
+		if releaseData.Namespace == namespace && releaseData.Status == rspb.Status_DEPLOYED.String() {
+			deployed = true
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return deployed, nil
+}
+
+// GetConfigMapForRelease returns the tiller configmap of a release revision
+func GetConfigMapForRelease(name string, revision int32, namespace string) (*corev1.ConfigMap, error) {
+	return GetConfigMapForReleaseWithKubeConfig(name, revision, namespace, "", "")
+}
+
+// GetConfigMapForReleaseWithKubeConfig returns the tiller configmap of a release revision
+func GetConfigMapForReleaseWithKubeConfig(name string, revision int32, namespace, kubeConfigFile, context string) (*corev1.ConfigMap, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).GetConfigMapForRelease(name, revision, namespace)
+}
+
+// GetConfigMapForRelease returns the tiller configmap of a release revision
+func (c *Client) GetConfigMapForRelease(name string, revision int32, namespace string) (*corev1.ConfigMap, error) {
+	configMap, err := c.ClientSet.CoreV1().ConfigMaps(namespace).Get(ctx.Background(), fmt.Sprintf("%s.v%d", name, revision), metav1.GetOptions{})
+	if err != nil {
+		return nil, wrapAPIError(err)
+	}
+	return configMap, nil
+}
+
+// GetSecretForRelease returns the tiller secret of a release revision
+func GetSecretForRelease(name string, revision int32, namespace string) (*corev1.Secret, error) {
+	return GetSecretForReleaseWithKubeConfig(name, revision, namespace, "", "")
+}
+
+// GetSecretForReleaseWithKubeConfig returns the tiller secret of a release revision
+func GetSecretForReleaseWithKubeConfig(name string, revision int32, namespace, kubeConfigFile, context string) (*corev1.Secret, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).GetSecretForRelease(name, revision, namespace)
+}
+
+// GetSecretForRelease returns the tiller secret of a release revision
+func (c *Client) GetSecretForRelease(name string, revision int32, namespace string) (*corev1.Secret, error) {
+	secret, err := c.ClientSet.CoreV1().Secrets(namespace).Get(ctx.Background(), fmt.Sprintf("%s.v%d", name, revision), metav1.GetOptions{})
+	if err != nil {
+		return nil, wrapAPIError(err)
+	}
+	return secret, nil
+}
+
+// ResolveReleaseNamespace returns the namespace of a release, failing if it exists in more than one namespace
+func ResolveReleaseNamespace(releaseName string) (string, error) {
+	return ResolveReleaseNamespaceWithKubeConfig(releaseName, "", "")
+}
+
+// ResolveReleaseNamespaceWithKubeConfig returns the namespace of a release, failing if it exists in more than one namespace
+func ResolveReleaseNamespaceWithKubeConfig(releaseName, kubeConfigFile, context string) (string, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).ResolveReleaseNamespace(releaseName, ListOptions{})
+}
+
+// ResolveReleaseNamespace returns the namespace of a release, failing if it exists in more than one namespace
+func (c *Client) ResolveReleaseNamespace(releaseName string, o ListOptions) (string, error) {
+	o.ReleaseName = releaseName
+	releases, err := c.ListReleases(o)
+	if err != nil {
+		return "", err
+	}
+	uniqNamespaces := make(map[string]string)
+	for _, r := range releases {
+		uniqNamespaces[r.Namespace] = ""
+	}
+	var namespaces []string
+	for namespace := range uniqNamespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	switch len(namespaces) {
+	case 0:
+		return "", fmt.Errorf("release %q: %w", releaseName, ErrNotFound)
+	case 1:
+		return namespaces[0], nil
+	}
+	return "", fmt.Errorf("release %q is ambiguous, exists in %d namespaces: %s", releaseName, len(namespaces), strings.Join(namespaces, ", "))
+}
+
+// CurrentRevision returns the DEPLOYED revision of the release named in the provided options
+func CurrentRevision(o ListOptions) (*ReleaseData, error) {
+	return CurrentRevisionWithKubeConfig(o, "", "")
+}
+
+// CurrentRevisionWithKubeConfig returns the DEPLOYED revision of the release named in the provided options
+func CurrentRevisionWithKubeConfig(o ListOptions, kubeConfigFile, context string) (*ReleaseData, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).CurrentRevision(o)
+}
+
+// CurrentRevision returns the DEPLOYED revision of the release named in the provided options
+func (c *Client) CurrentRevision(o ListOptions) (*ReleaseData, error) {
+	if o.ReleaseName == "" {
+		return nil, fmt.Errorf("a release name is required")
+	}
+	var deployed []ReleaseData
+	err := c.forEachRelease(o, func(releaseData *ReleaseData) bool {
+		if releaseData.Status == rspb.Status_DEPLOYED.String() {
+			deployed = append(deployed, *releaseData)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(deployed) {
+	case 0:
+		return nil, fmt.Errorf("release %q has no deployed revision", o.ReleaseName)
+	case 1:
+		return &deployed[0], nil
+	}
+	var revisions []string
+	for _, r := range deployed {
+		revisions = append(revisions, strconv.Itoa(int(r.Revision)))
+	}
+	return nil, fmt.Errorf("release %q has %d deployed revisions: %s", o.ReleaseName, len(deployed), strings.Join(revisions, ", "))
+}
+
+// ErrNoHistory is returned when a release has no revision older than its latest one
+var ErrNoHistory = errors.New("release has no previous revision")
+
+// GetReleasePreviousRevision returns the latest DEPLOYED or SUPERSEDED revision of a release older than its latest revision
+func GetReleasePreviousRevision(name string, o ListOptions) (*ReleaseData, error) {
+	return GetReleasePreviousRevisionWithKubeConfig(name, o, "", "")
+}
+
+// GetReleasePreviousRevisionWithKubeConfig returns the latest DEPLOYED or SUPERSEDED revision of a release older than its latest revision
+func GetReleasePreviousRevisionWithKubeConfig(name string, o ListOptions, kubeConfigFile, context string) (*ReleaseData, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).GetReleasePreviousRevision(name, o)
+}
+
+// GetReleasePreviousRevision returns the latest DEPLOYED or SUPERSEDED revision of a release older than its latest revision
+func (c *Client) GetReleasePreviousRevision(name string, o ListOptions) (*ReleaseData, error) {
+	o.ReleaseName = name
+	releases, err := c.ListReleases(o)
+	if err != nil {
+		return nil, err
+	}
+	if len(releases) == 0 {
+		return nil, fmt.Errorf("release %q: %w", name, ErrNotFound)
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Revision > releases[j].Revision
+	})
+	for _, r := range releases[1:] {
+		if r.Status == rspb.Status_DEPLOYED.String() || r.Status == rspb.Status_SUPERSEDED.String() {
+			return &r, nil
+		}
+	}
+	return nil, ErrNoHistory
+}
+
+type RevisionDiff struct {
+	Name             string
+	RevisionA        int32
+	RevisionB        int32
+	ChartVersionA    string
+	ChartVersionB    string
+	StatusA          string
+	StatusB          string
+	ValuesChanged    bool
+	AddedResources   []ManifestResource
+	RemovedResources []ManifestResource
+	ChangedResources []ManifestResource
+}
+
+// CompareRevisions returns the differences (chart version, status, values and manifest resources)
+// between two revisions of the release named in the provided options
+func CompareRevisions(o ListOptions, revA, revB int32) (RevisionDiff, error) {
+	return CompareRevisionsWithKubeConfig(o, revA, revB, "", "")
+}
+
+// CompareRevisionsWithKubeConfig returns the differences (chart version, status, values and manifest resources)
+// between two revisions of the release named in the provided options
+func CompareRevisionsWithKubeConfig(o ListOptions, revA, revB int32, kubeConfigFile, context string) (RevisionDiff, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).CompareRevisions(o, revA, revB)
+}
+
+// CompareRevisions returns the differences (chart version, status, values and manifest resources)
+// between two revisions of the release named in the provided options
+func (c *Client) CompareRevisions(o ListOptions, revA, revB int32) (RevisionDiff, error) {
+	if o.ReleaseName == "" {
+		return RevisionDiff{}, fmt.Errorf("a release name is required")
+	}
+	o.IncludeValues = true
+	o.IncludeManifest = boolPtr(true)
+	var releases [2]*ReleaseData
+	for i, revision := range []int32{revA, revB} {
+		r, err := c.GetRelease(o.ReleaseName, revision, o)
+		if err != nil {
+			return RevisionDiff{}, err
+		}
+		if r == nil {
+			return RevisionDiff{}, fmt.Errorf("release %q revision %d: %w", o.ReleaseName, revision, ErrNotFound)
+		}
+		releases[i] = r
+	}
+	a, b := releases[0], releases[1]
+
+	diff := RevisionDiff{
+		Name:          o.ReleaseName,
+		RevisionA:     revA,
+		RevisionB:     revB,
+		ChartVersionA: a.ChartVersion,
+		ChartVersionB: b.ChartVersion,
+		StatusA:       a.Status,
+		StatusB:       b.Status,
+		ValuesChanged: !valuesEqual(a.ConfigValues, b.ConfigValues),
+	}
+	objectsA, err := parseManifest(a.Manifest)
+	if err != nil {
+		return RevisionDiff{}, fmt.Errorf("could not parse manifest of revision %d: %v", revA, err)
+	}
+	objectsB, err := parseManifest(b.Manifest)
+	if err != nil {
+		return RevisionDiff{}, fmt.Errorf("could not parse manifest of revision %d: %v", revB, err)
+	}
+	// resources are matched by kind, namespace and name, so an api version change is reported as a change
+	key := func(object unstructured.Unstructured) string {
+		return fmt.Sprintf("%s/%s/%s", object.GetKind(), object.GetNamespace(), object.GetName())
+	}
+	resource := func(object unstructured.Unstructured) ManifestResource {
+		return ManifestResource{
+			APIVersion: object.GetAPIVersion(),
+			Kind:       object.GetKind(),
+			Name:       object.GetName(),
+			Namespace:  object.GetNamespace(),
+		}
+	}
+	byKeyA := make(map[string]unstructured.Unstructured)
+	for _, object := range objectsA {
+		byKeyA[key(object)] = object
+	}
+	byKeyB := make(map[string]unstructured.Unstructured)
+	for _, object := range objectsB {
+		byKeyB[key(object)] = object
+	}
+	for _, object := range objectsB {
+		previous, ok := byKeyA[key(object)]
+		if !ok {
+			diff.AddedResources = append(diff.AddedResources, resource(object))
+			continue
+		}
+		if !reflect.DeepEqual(previous.Object, object.Object) {
+			diff.ChangedResources = append(diff.ChangedResources, resource(object))
+		}
+	}
+	for _, object := range objectsA {
+		if _, ok := byKeyB[key(object)]; !ok {
+			diff.RemovedResources = append(diff.RemovedResources, resource(object))
+		}
+	}
+	return diff, nil
+}
+
+// valuesEqual returns true if two sets of values are equal, treating nil values (no values) as empty values ({})
+func valuesEqual(a, b map[string]interface{}) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// RevisionRange returns the lowest and highest stored revisions of the release named in the provided options,
+// and the number of stored revisions (count < max-min+1 means revisions are missing)
+func RevisionRange(o ListOptions) (min, max int32, count int, err error) {
+	return RevisionRangeWithKubeConfig(o, "", "")
+}
+
+// RevisionRangeWithKubeConfig returns the lowest and highest stored revisions of the release named in the provided options,
+// and the number of stored revisions (count < max-min+1 means revisions are missing)
+func RevisionRangeWithKubeConfig(o ListOptions, kubeConfigFile, context string) (min, max int32, count int, err error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).RevisionRange(o)
+}
+
+// RevisionRange returns the lowest and highest stored revisions of the release named in the provided options,
+// and the number of stored revisions (count < max-min+1 means revisions are missing)
+func (c *Client) RevisionRange(o ListOptions) (min, max int32, count int, err error) {
+	if o.ReleaseName == "" {
+		return 0, 0, 0, fmt.Errorf("a release name is required")
+	}
+	err = c.forEachRelease(o, func(releaseData *ReleaseData) bool {
+		if count == 0 || releaseData.Revision < min {
+			min = releaseData.Revision
+		}
+		if count == 0 || releaseData.Revision > max {
+			max = releaseData.Revision
+		}
+		count++
+		return true
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if count == 0 {
+		return 0, 0, 0, fmt.Errorf("release %q: %w", o.ReleaseName, ErrNotFound)
+	}
+	return min, max, count, nil
+}
+
+// GetReleaseStatus returns the status of the latest revision of a release, decoding only that revision
+func GetReleaseStatus(name string, o ListOptions) (string, error) {
+	return GetReleaseStatusWithKubeConfig(name, o, "", "")
+}
+
+// GetReleaseStatusWithKubeConfig returns the status of the latest revision of a release, decoding only that revision
+func GetReleaseStatusWithKubeConfig(name string, o ListOptions, kubeConfigFile, context string) (string, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).GetReleaseStatus(name, o)
+}
+
+// GetReleaseStatus returns the status of the latest revision of a release, decoding only that revision
+func (c *Client) GetReleaseStatus(name string, o ListOptions) (string, error) {
+	latest, err := c.getLatestStorageObject(name, o)
+	if err != nil {
+		return "", err
+	}
+	releaseData, err := c.decodeStorageObject(*latest, false)
+	if err != nil {
+		return "", fmt.Errorf("could not decode release %s: %v", latest.Name, err)
+	}
+	return releaseData.Status, nil
+}
+
+// getLatestStorageObject returns the tiller resource (configmap/secret) of the latest revision of a release
+func (c *Client) getLatestStorageObject(name string, o ListOptions) (*storageObject, error) {
+	o.ReleaseName = name
+	items, err := c.listStorageObjects(o)
+	if err != nil {
+		return nil, err
+	}
+	var latest *storageObject
+	var latestRevision int32
+	for i := range items {
+		_, revision, err := ParseTillerObjectName(items[i].Name)
+		if err != nil {
+			continue
+		}
+		if latest == nil || revision > latestRevision {
+			latest, latestRevision = &items[i], revision
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("release %q: %w", name, ErrNotFound)
+	}
+	return latest, nil
+}
+
+// GetChartValues returns the values of the latest revision of a release: the chart default values
+// (including the ones of its dependencies) overridden by the user-supplied values, as `helm get values --all`
+func GetChartValues(name string, o ListOptions) (map[string]interface{}, error) {
+	return GetChartValuesWithKubeConfig(name, o, "", "")
+}
+
+// GetChartValuesWithKubeConfig returns the values of the latest revision of a release: the chart default values
+// (including the ones of its dependencies) overridden by the user-supplied values, as `helm get values --all`
+func GetChartValuesWithKubeConfig(name string, o ListOptions, kubeConfigFile, context string) (map[string]interface{}, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).GetChartValues(name, o)
+}
+
+// GetChartValues returns the values of the latest revision of a release: the chart default values
+// (including the ones of its dependencies) overridden by the user-supplied values, as `helm get values --all`
+func (c *Client) GetChartValues(name string, o ListOptions) (map[string]interface{}, error) {
+	latest, err := c.getLatestStorageObject(name, o)
+	if err != nil {
+		return nil, err
+	}
+	rls, err := DecodeRelease(latest.itemReleaseData)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode release %s: %v", latest.Name, err)
+	}
+	defaults, err := chartDefaultValues(rls.GetChart())
+	if err != nil {
+		return nil, err
+	}
+	config, err := parseValues(rls.GetConfig().GetRaw())
+	if err != nil {
+		return nil, fmt.Errorf("could not parse values of release %s: %v", latest.Name, err)
+	}
+	return mergeValues(defaults, config), nil
+}
+
+// chartDefaultValues returns the default values of a chart, with the default values of each dependency
+// under the dependency name, overridden by the values of the parent chart
+func chartDefaultValues(ch *cpb.Chart) (map[string]interface{}, error) {
+	values, err := parseValues(ch.GetValues().GetRaw())
+	if err != nil {
+		return nil, fmt.Errorf("could not parse values of chart %s: %v", ch.GetMetadata().GetName(), err)
+	}
+	for _, dependency := range ch.GetDependencies() {
+		dependencyValues, err := chartDefaultValues(dependency)
+		if err != nil {
+			return nil, err
+		}
+		dependencyName := dependency.GetMetadata().GetName()
+		parentValues, _ := values[dependencyName].(map[string]interface{})
+		values[dependencyName] = mergeValues(dependencyValues, parentValues)
+	}
+	return values, nil
+}
+
+// parseValues parses yaml values, returning an empty map for empty values
+func parseValues(raw string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if strings.TrimSpace(raw) == "" {
+		return values, nil
+	}
+	if err := yaml.Unmarshal([]byte(raw), &values); err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+	return values, nil
+}
+
+// redactedValue replaces the values redacted by RedactValues
+const redactedValue = "***"
+
+// DefaultRedactedKeys are common names of sensitive keys, to be passed to RedactValues
+var DefaultRedactedKeys = []string{"password", "secret", "token", "key"}
+
+// RedactValues returns yaml values with the values of sensitive keys replaced by "***". Keys are redacted if their
+// whole name or dotted path (e.g. db.user) is one of the provided keys (e.g. DefaultRedactedKeys)
+func RedactValues(values string, keys []string) (string, error) {
+	parsed, err := parseValues(values)
+	if err != nil {
+		return "", err
+	}
+	redactMap(parsed, "", keys)
+	b, err := sigsyaml.Marshal(parsed)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// redactMap redacts the sensitive keys of values in place, path being the dotted path of values
+func redactMap(values map[string]interface{}, path string, keys []string) {
+	for k, v := range values {
+		keyPath := k
+		if path != "" {
+			keyPath = path + "." + k
+		}
+		if isRedactedKey(k, keyPath, keys) {
+			values[k] = redactedValue
+			continue
+		}
+		redactValue(v, keyPath, keys)
+	}
+}
+
+// redactValue redacts the sensitive keys of nested maps and lists
+func redactValue(v interface{}, path string, keys []string) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		redactMap(value, path, keys)
+	case []interface{}:
+		for _, item := range value {
+			redactValue(item, path, keys)
+		}
+	}
+}
+
+// isRedactedKey returns true if the name or the dotted path of a key is one of the provided keys
+func isRedactedKey(name, path string, keys []string) bool {
+	for _, key := range keys {
+		if key == name || key == path {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeValues returns base with override merged on top of it, merging nested maps
+func mergeValues(base, override map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		baseMap, baseOK := merged[k].(map[string]interface{})
+		overrideMap, overrideOK := v.(map[string]interface{})
+		if baseOK && overrideOK {
+			merged[k] = mergeValues(baseMap, overrideMap)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// GetReleaseDataByRevision returns the decoded release data of a specific release revision, or nil if not found
+func GetReleaseDataByRevision(name string, revision int32, o ListOptions) (*ReleaseData, error) {
+	return GetReleaseDataByRevisionWithKubeConfig(name, revision, o, "", "")
+}
+
+// GetReleaseDataByRevisionWithKubeConfig returns the decoded release data of a specific release revision, or nil if not found
+func GetReleaseDataByRevisionWithKubeConfig(name string, revision int32, o ListOptions, kubeConfigFile, context string) (*ReleaseData, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).GetRelease(name, revision, o)
+}
+
+// GetRelease returns the decoded release data of a specific release revision, or nil if not found
+func (c *Client) GetRelease(name string, revision int32, o ListOptions) (*ReleaseData, error) {
+	if o.TillerNamespace == "" {
+		o.TillerNamespace = "kube-system"
+	}
+	storageTypes, err := c.getStorageTypes(o)
+	if err != nil {
+		return nil, err
+	}
+	for _, storage := range storageTypes {
+		var item storageObject
+		switch storage {
+		case "secrets":
+			secret, err := c.GetSecretForRelease(name, revision, o.TillerNamespace)
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			item = storageObject{secret.ObjectMeta, secretReleaseData(secret.Data, o.ReleaseDataKey)}
+		case "configmaps":
+			configMap, err := c.GetConfigMapForRelease(name, revision, o.TillerNamespace)
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			item = storageObject{configMap.ObjectMeta, configMapReleaseData(configMap.Data, o.ReleaseDataKey)}
+		}
+		releaseData, err := c.decodeStorageObject(item, o.IncludeValues)
+		if err != nil {
+			if o.OnDecodeError != nil {
+				return nil, o.OnDecodeError(err, item.Name)
+			}
+			return nil, fmt.Errorf("could not decode release %s: %v", item.Name, err)
+		}
+		if !includeManifest(o) {
+			releaseData.Manifest = ""
+		}
+		releaseData.ModifiedBy = getModifiedBy(item.ObjectMeta, o.ModifiedByKey)
+		return releaseData, nil
+	}
+
+	return nil, nil
+}
+
+// releaseStatusPollInterval is the interval between polls of WaitForReleaseStatus
+const releaseStatusPollInterval = 2 * time.Second
+
+// WaitForReleaseStatus waits until the latest revision of a release has a provided status
+func WaitForReleaseStatus(name, status string, timeout time.Duration, o ListOptions) error {
+	return WaitForReleaseStatusWithKubeConfig(name, status, timeout, o, "", "")
+}
+
+// WaitForReleaseStatusWithKubeConfig waits until the latest revision of a release has a provided status
+func WaitForReleaseStatusWithKubeConfig(name, status string, timeout time.Duration, o ListOptions, kubeConfigFile, context string) error {
+	return NewClientWithKubeConfig(kubeConfigFile, context).WaitForReleaseStatus(name, status, timeout, o)
+}
+
+// WaitForReleaseStatus waits until the latest revision of a release has a provided status
+func (c *Client) WaitForReleaseStatus(name, status string, timeout time.Duration, o ListOptions) error {
+	o.ReleaseName = name
+	if o.TillerNamespace == "" {
+		o.TillerNamespace = "kube-system"
+	}
+	// detect the storage types once instead of on every poll
+	storageTypes, err := c.getStorageTypes(o)
+	if err != nil {
+		return err
+	}
+	o.StorageTypes = storageTypes
+	o.AllStorageTypes = false
+	releases, err := c.ListReleases(o)
+	if err != nil {
+		return err
+	}
+	var revision int32
+	for _, r := range releases {
+		if r.Revision > revision {
+			revision = r.Revision
+		}
+	}
+
+	err = wait.PollImmediate(releaseStatusPollInterval, timeout, func() (bool, error) {
+		// follow revisions created while waiting (e.g. an upgrade in progress)
+		for {
+			next, err := c.GetRelease(name, revision+1, o)
+			if err != nil {
+				return false, err
+			}
+			if next == nil {
+				break
+			}
+			revision++
+		}
+		if revision == 0 {
+			return false, nil
+		}
+		current, err := c.GetRelease(name, revision, o)
+		if err != nil {
+			return false, err
+		}
+		return current != nil && current.Status == status, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("timed out waiting for release %q to reach status %s", name, status)
+	}
+	return err
+}
+
+// ReleaseWithResources is a release with the live resources of its manifest
+type ReleaseWithResources struct {
+	ReleaseData
+	Resources []unstructured.Unstructured
+}
+
+// ListReleasesWithResources lists all releases according to provided options, with the live resources of their manifest.
+// The resources are read with a provided dynamic client (see NewClientWithDynamic)
+func ListReleasesWithResources(o ListOptions, clientSet *kubernetes.Clientset, dynamicClient dynamic.Interface) ([]ReleaseWithResources, error) {
+	return NewClientWithDynamic(clientSet, dynamicClient).ListReleasesWithResources(o)
+}
+
+// ListReleasesWithResources lists all releases according to provided options, with the live resources of their manifest
+func (c *Client) ListReleasesWithResources(o ListOptions) ([]ReleaseWithResources, error) {
+	o.IncludeManifest = boolPtr(true)
+	releases, err := c.ListReleases(o)
+	if err != nil {
+		return nil, err
+	}
+	mapper := c.newRESTMapper()
+	var releasesWithResources []ReleaseWithResources
+	for _, r := range releases {
+		resources, err := c.getReleaseResources(mapper, r)
+		if err != nil {
+			return nil, err
+		}
+		releasesWithResources = append(releasesWithResources, ReleaseWithResources{r, resources})
+	}
+	return releasesWithResources, nil
+}
+
+// GetReleaseResources returns the live resources of a release manifest, skipping the ones which do not exist
+func GetReleaseResources(r ReleaseData) ([]unstructured.Unstructured, error) {
+	return GetReleaseResourcesWithKubeConfig(r, "", "")
+}
+
+// GetReleaseResourcesWithKubeConfig returns the live resources of a release manifest, skipping the ones which do not exist
+func GetReleaseResourcesWithKubeConfig(r ReleaseData, kubeConfigFile, context string) ([]unstructured.Unstructured, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).GetReleaseResources(r)
+}
+
+// GetReleaseResources returns the live resources of a release manifest, skipping the ones which do not exist
+func (c *Client) GetReleaseResources(r ReleaseData) ([]unstructured.Unstructured, error) {
+	return c.getReleaseResources(c.newRESTMapper(), r)
+}
+
+func (c *Client) getReleaseResources(mapper meta.RESTMapper, r ReleaseData) ([]unstructured.Unstructured, error) {
+	objects, err := parseManifest(r.Manifest)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse manifest of release %s revision %d: %v", r.Name, r.Revision, err)
+	}
+	var resources []unstructured.Unstructured
+	for _, object := range objects {
+		live, err := c.getLiveObject(mapper, object, r.Namespace)
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, *live)
+	}
+	return resources, nil
+}
+
+// DriftItem is a difference between a resource declared in a release manifest and the live resource
+type DriftItem struct {
+	Release   string
+	Kind      string
+	Name      string
+	Namespace string
+	Field     string
+	Expected  string
+	Actual    string
+}
+
+// ReleaseDrift compares the resources of the deployed revision of releases with their manifest
+// (existence, replicas, container images and labels) and returns the differences
+func ReleaseDrift(o ListOptions) ([]DriftItem, error) {
+	return ReleaseDriftWithKubeConfig(o, "", "")
+}
+
+// ReleaseDriftWithKubeConfig compares the resources of the deployed revision of releases with their manifest
+// (existence, replicas, container images and labels) and returns the differences
+func ReleaseDriftWithKubeConfig(o ListOptions, kubeConfigFile, context string) ([]DriftItem, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).ReleaseDrift(o)
+}
+
+// ReleaseDrift compares the resources of the deployed revision of releases with their manifest
+// (existence, replicas, container images and labels) and returns the differences
+func (c *Client) ReleaseDrift(o ListOptions) ([]DriftItem, error) {
+	o.IncludeManifest = boolPtr(true)
+	releases, err := c.ListReleases(o)
+	if err != nil {
+		return nil, err
+	}
+	mapper := c.newRESTMapper()
+
+	var drift []DriftItem
+	for _, r := range releases {
+		if r.Status != rspb.Status_DEPLOYED.String() {
+			continue
+		}
+		objects, err := parseManifest(r.Manifest)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse manifest of release %s revision %d: %v", r.Name, r.Revision, err)
+		}
+		for _, object := range objects {
+			item := DriftItem{
+				Release:   r.Name,
+				Kind:      object.GetKind(),
+				Name:      object.GetName(),
+				Namespace: object.GetNamespace(),
+			}
+			live, err := c.getLiveObject(mapper, object, r.Namespace)
+			// a kind which is no longer served (e.g. a removed CRD) is missing as well
+			if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+				item.Field, item.Expected, item.Actual = "existence", "present", "missing"
+				drift = append(drift, item)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, d := range compareObjects(object, *live) {
+				item.Field, item.Expected, item.Actual = d[0], d[1], d[2]
+				drift = append(drift, item)
+			}
+		}
+	}
+	return drift, nil
+}
+
+// compareObjects returns the differences (field, expected, actual) of replicas, container images and labels
+func compareObjects(declared, live unstructured.Unstructured) [][3]string {
+	var diffs [][3]string
+	if replicas, ok, _ := unstructured.NestedInt64(declared.Object, "spec", "replicas"); ok {
+		liveReplicas, _, _ := unstructured.NestedInt64(live.Object, "spec", "replicas")
+		if replicas != liveReplicas {
+			diffs = append(diffs, [3]string{"spec.replicas", strconv.FormatInt(replicas, 10), strconv.FormatInt(liveReplicas, 10)})
+		}
+	}
+	liveImages := getContainerImages(live)
+	declaredImages := getContainerImages(declared)
+	var containers []string
+	for container := range declaredImages {
+		containers = append(containers, container)
+	}
+	sort.Strings(containers)
+	for _, container := range containers {
+		if declaredImages[container] != liveImages[container] {
+			diffs = append(diffs, [3]string{"image/" + container, declaredImages[container], liveImages[container]})
+		}
+	}
+	liveLabels := live.GetLabels()
+	declaredLabels := declared.GetLabels()
+	var labels []string
+	for label := range declaredLabels {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		if declaredLabels[label] != liveLabels[label] {
+			diffs = append(diffs, [3]string{"labels/" + label, declaredLabels[label], liveLabels[label]})
+		}
+	}
+	return diffs
+}
+
+// NewListOptionsFromEnv returns ListOptions populated from the HELM_TILLER_NAMESPACE, HELM_TILLER_LABEL,
+// HELM_RELEASE_NAME and HELM_DRIVER environment variables
+func NewListOptionsFromEnv() ListOptions {
+	return ListOptions{
+		ReleaseName:     os.Getenv("HELM_RELEASE_NAME"),
+		TillerNamespace: os.Getenv("HELM_TILLER_NAMESPACE"),
+		TillerLabel:     os.Getenv("HELM_TILLER_LABEL"),
+		StorageDriver:   os.Getenv("HELM_DRIVER"),
+	}
+}
+
+// MergeListOptions returns base with the non-empty fields of override applied on top of it
+func MergeListOptions(base, override ListOptions) ListOptions {
+	merged := base
+	mergeString := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	mergeString(&merged.ReleaseName, override.ReleaseName)
+	mergeString(&merged.TillerNamespace, override.TillerNamespace)
+	mergeString(&merged.TillerLabel, override.TillerLabel)
+	mergeString(&merged.StorageDriver, override.StorageDriver)
+	mergeString(&merged.ExtractManifestLabel, override.ExtractManifestLabel)
+	mergeString(&merged.ChartSource, override.ChartSource)
+	mergeString(&merged.FieldSelector, override.FieldSelector)
+	mergeString(&merged.ResourceVersion, override.ResourceVersion)
+	mergeString(&merged.ReleaseDataKey, override.ReleaseDataKey)
+	mergeString(&merged.ModifiedByKey, override.ModifiedByKey)
+	mergeString(&merged.ModifiedBy, override.ModifiedBy)
+	if len(override.StorageTypes) > 0 {
+		merged.StorageTypes = append([]string(nil), override.StorageTypes...)
+	}
+	merged.AllStorageTypes = merged.AllStorageTypes || override.AllStorageTypes
+	merged.OnlyWithTests = merged.OnlyWithTests || override.OnlyWithTests
+	merged.IncludeValues = merged.IncludeValues || override.IncludeValues
+	merged.SkipManifest = merged.SkipManifest || override.SkipManifest
+	merged.DryRun = merged.DryRun || override.DryRun
+	if override.MaxResults != 0 {
+		merged.MaxResults = override.MaxResults
+	}
+	if override.MaxReleases != 0 {
+		merged.MaxReleases = override.MaxReleases
+	}
+	if override.OnDecodeError != nil {
+		merged.OnDecodeError = override.OnDecodeError
+	}
+	if override.Metrics != nil {
+		merged.Metrics = override.Metrics
+	}
+	if override.IncludeManifest != nil {
+		merged.IncludeManifest = boolPtr(*override.IncludeManifest)
+	} else if override.SkipManifest {
+		merged.IncludeManifest = new(bool)
+	}
+	return merged
+}
+
+// ValidateListOptions returns an error if the provided options are invalid
+func ValidateListOptions(o ListOptions) error {
+	if o.ModifiedBy != "" && o.ModifiedByKey == "" {
+		return fmt.Errorf("filtering by modifying user requires a modified by key")
+	}
+	if o.ReleaseName != "" {
+		if errs := validation.IsDNS1123Subdomain(o.ReleaseName); len(errs) > 0 {
+			return fmt.Errorf("invalid release name %q: %s", o.ReleaseName, strings.Join(errs, ", "))
+		}
+	}
+	if o.FieldSelector != "" {
+		if _, err := fields.ParseSelector(o.FieldSelector); err != nil {
+			return fmt.Errorf("invalid field selector %q: %v", o.FieldSelector, err)
+		}
+	}
+	return nil
+}
+
+// forEachRelease calls fn for each release according to provided options until fn returns false
+func (c *Client) forEachRelease(o ListOptions, fn func(*ReleaseData) bool) error {
+	start := time.Now()
+	items, err := c.listStorageObjects(o)
+	if err != nil {
+		return err
+	}
+	reportMetric(o, "list", time.Since(start))
+
+	maxResults := o.MaxResults
+	if o.MaxReleases > 0 {
+		if maxResults == 0 || o.MaxReleases < maxResults {
+			maxResults = o.MaxReleases
+		}
+		// the returned page is stable: releases sorted by name and revision
+		sortStorageObjects(items)
+	}
+	var decodeDuration, filterDuration time.Duration
+	defer func() {
+		reportMetric(o, "decode", decodeDuration)
+		reportMetric(o, "filter", filterDuration)
+	}()
+	results := 0
+	for _, item := range items {
+		// the modifying user is matched on the tiller resource metadata, before decoding
+		if o.ModifiedBy != "" && getModifiedBy(item.ObjectMeta, o.ModifiedByKey) != o.ModifiedBy {
+			continue
+		}
+		start = time.Now()
+		releaseData, err := c.decodeStorageObject(item, o.IncludeValues)
+		decodeDuration += time.Since(start)
+		if err != nil {
+			if o.OnDecodeError != nil {
+				if err := o.OnDecodeError(err, item.Name); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		releaseData.ModifiedBy = getModifiedBy(item.ObjectMeta, o.ModifiedByKey)
+		start = time.Now()
+		accepted := filterRelease(o, releaseData)
+		filterDuration += time.Since(start)
+		if !accepted {
+			continue
+		}
+		// once the limit is reached, decoding stops at the first remaining matching release
+		if maxResults > 0 && results >= maxResults {
+			return ErrTruncated
+		}
+		results++
+		if !fn(releaseData) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// sortStorageObjects sorts tiller resources (configmaps/secrets) by release name and revision
+func sortStorageObjects(items []storageObject) {
+	sort.SliceStable(items, func(i, j int) bool {
+		nameI, revisionI, errI := ParseTillerObjectName(items[i].Name)
+		nameJ, revisionJ, errJ := ParseTillerObjectName(items[j].Name)
+		if errI != nil || errJ != nil || nameI != nameJ {
+			return items[i].Name < items[j].Name
+		}
+		return revisionI < revisionJ
+	})
+}
+
+// ForEachRelease calls fn for each release according to provided options until fn returns false,
+// without holding all releases in memory
+func ForEachRelease(o ListOptions, fn func(ReleaseData) bool) error {
+	return ForEachReleaseWithKubeConfig(o, fn, "", "")
+}
+
+// ForEachReleaseWithKubeConfig calls fn for each release according to provided options until fn returns false,
+// without holding all releases in memory
+func ForEachReleaseWithKubeConfig(o ListOptions, fn func(ReleaseData) bool, kubeConfigFile, context string) error {
+	return NewClientWithKubeConfig(kubeConfigFile, context).ForEachRelease(o, fn)
+}
+
+// ForEachRelease calls fn for each release according to provided options until fn returns false,
+// without holding all releases in memory
+func (c *Client) ForEachRelease(o ListOptions, fn func(ReleaseData) bool) error {
+	return c.forEachRelease(o, func(releaseData *ReleaseData) bool {
+		return fn(*releaseData)
+	})
+}
+
+// filterRelease returns true if a release matches the provided options, setting the fields populated according to them
+func filterRelease(o ListOptions, releaseData *ReleaseData) bool {
+	if o.OnlyWithTests && !releaseData.HasTests {
+		return false
+	}
+	if o.ChartSource != "" && !hasChartSource(releaseData, o.ChartSource) {
+		return false
+	}
+	if o.ExtractManifestLabel != "" {
+		releaseData.ManifestLabel, _ = GetManifestLabel(releaseData.Manifest, o.ExtractManifestLabel)
+	}
+	if !includeManifest(o) {
+		releaseData.Manifest = ""
+	}
+	return true
+}
+
+// reportMetric calls ListOptions.Metrics if set
+func reportMetric(o ListOptions, stage string, d time.Duration) {
+	if o.Metrics != nil {
+		o.Metrics(stage, d)
+	}
+}
+
+// getModifiedBy returns the value of a label, or else an annotation, of a tiller resource
+func getModifiedBy(objectMeta metav1.ObjectMeta, key string) string {
+	if key == "" {
+		return ""
+	}
+	if value, ok := objectMeta.Labels[key]; ok {
+		return value
+	}
+	return objectMeta.Annotations[key]
+}
+
+// includeManifest returns true unless the provided options exclude the release manifest
+func includeManifest(o ListOptions) bool {
+	if o.IncludeManifest != nil {
+		return *o.IncludeManifest
+	}
+	return !o.SkipManifest
+}
+
+// boolPtr returns a pointer to a copy of b
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// hasChartSource returns true if one of the chart sources of a release contains source
+func hasChartSource(releaseData *ReleaseData, source string) bool {
+	for _, s := range releaseData.ChartSources {
+		if strings.Contains(s, source) {
+			return true
+		}
+	}
+	return false
+}
+
+// listStorageObjects lists all tiller resources (configmaps/secrets) according to provided options
+func (c *Client) listStorageObjects(o ListOptions) ([]storageObject, error) {
+	if err := ValidateListOptions(o); err != nil {
+		return nil, err
+	}
+	if o.TillerNamespace == "" {
+		o.TillerNamespace = "kube-system"
+	}
+	if o.TillerLabel == "" {
+		o.TillerLabel = "OWNER=TILLER"
+	}
+	if o.ReleaseName != "" {
+		o.TillerLabel += fmt.Sprintf(",NAME=%s", o.ReleaseName)
+	}
+	storageTypes, err := c.getStorageTypes(o)
+	if err != nil {
+		return nil, err
+	}
+	var items []storageObject
+	// releases stored in more than one storage type are only returned once
+	seen := make(map[string]bool)
+	add := func(item storageObject) {
+		if seen[item.Name] {
+			return
+		}
+		seen[item.Name] = true
+		items = append(items, item)
+	}
+	for _, storage := range storageTypes {
+		switch storage {
+		case "secrets":
+			secrets, err := c.ClientSet.CoreV1().Secrets(o.TillerNamespace).List(ctx.Background(), metav1.ListOptions{
+				LabelSelector: o.TillerLabel,
+				FieldSelector: o.FieldSelector,
+			})
+			if err != nil {
+				return nil, wrapAPIError(err)
+			}
+			for _, item := range secrets.Items {
+				add(storageObject{item.ObjectMeta, secretReleaseData(item.Data, o.ReleaseDataKey)})
+			}
+		case "configmaps":
+			configMaps, err := c.ClientSet.CoreV1().ConfigMaps(o.TillerNamespace).List(ctx.Background(), metav1.ListOptions{
+				LabelSelector: o.TillerLabel,
+				FieldSelector: o.FieldSelector,
+			})
+			if err != nil {
+				return nil, wrapAPIError(err)
+			}
+			for _, item := range configMaps.Items {
+				add(storageObject{item.ObjectMeta, configMapReleaseData(item.Data, o.ReleaseDataKey)})
+			}
+		}
+	}
+
+	return items, nil
+}
+
+// secretReleaseData returns the encoded release of a tiller secret, or an empty string if it has none,
+// see ListOptions.ReleaseDataKey
+func secretReleaseData(data map[string][]byte, key string) string {
+	var keys []string
+	for k := range data {
+		keys = append(keys, k)
+	}
+	k, ok := releaseDataKey(keys, key)
+	if !ok {
+		return ""
+	}
+	return (string)(data[k])
+}
+
+// configMapReleaseData returns the encoded release of a tiller configmap, or an empty string if it has none,
+// see ListOptions.ReleaseDataKey
+func configMapReleaseData(data map[string]string, key string) string {
+	var keys []string
+	for k := range data {
+		keys = append(keys, k)
+	}
+	k, ok := releaseDataKey(keys, key)
+	if !ok {
+		return ""
+	}
+	return data[k]
+}
+
+// releaseDataKey returns the data key holding the encoded release among the keys of a tiller resource,
+// or false if none of them does
+func releaseDataKey(keys []string, key string) (string, bool) {
+	if key == "" {
+		key = "release"
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if k == key {
+			return k, true
+		}
+	}
+	for _, k := range keys {
+		if strings.HasPrefix(k, "release") {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// GetReleaseSize returns the stored (encoded) size in bytes of all revisions of a release
+func GetReleaseSize(name string, o ListOptions) (int64, error) {
+	return GetReleaseSizeWithKubeConfig(name, o, "", "")
+}
+
+// GetReleaseSizeWithKubeConfig returns the stored (encoded) size in bytes of all revisions of a release
+func GetReleaseSizeWithKubeConfig(name string, o ListOptions, kubeConfigFile, context string) (int64, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).GetReleaseSize(name, o)
+}
+
+// GetReleaseSize returns the stored (encoded) size in bytes of all revisions of a release
+func (c *Client) GetReleaseSize(name string, o ListOptions) (int64, error) {
+	o.ReleaseName = name
+	items, err := c.listStorageObjects(o)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, item := range items {
+		size += int64(len(item.itemReleaseData))
+	}
+	return size, nil
+}
+
+// ReleaseSizes returns the decoded size in bytes of each release revision (keyed by <name>.v<revision>)
+func ReleaseSizes(o ListOptions) (map[string]int, error) {
+	return ReleaseSizesWithKubeConfig(o, "", "")
+}
+
+// ReleaseSizesWithKubeConfig returns the decoded size in bytes of each release revision (keyed by <name>.v<revision>)
+func ReleaseSizesWithKubeConfig(o ListOptions, kubeConfigFile, context string) (map[string]int, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).ReleaseSizes(o)
+}
+
+// ReleaseSizes returns the decoded size in bytes of each release revision (keyed by <name>.v<revision>)
+func (c *Client) ReleaseSizes(o ListOptions) (map[string]int, error) {
+	items, err := c.listStorageObjects(o)
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[string]int)
+	for _, item := range items {
+		b, err := decodeReleaseBytes(item.itemReleaseData)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode release %s: %v", item.Name, err)
+		}
+		sizes[item.Name] = len(b)
+	}
+	return sizes, nil
+}
+
+// FindReleasesUsingImage returns the releases with a manifest referencing a container image matching a provided string
+func FindReleasesUsingImage(image string, o ListOptions) ([]ReleaseData, error) {
+	return FindReleasesUsingImageWithKubeConfig(image, o, "", "")
+}
+
+// FindReleasesUsingImageWithKubeConfig returns the releases with a manifest referencing a container image matching a provided string
+func FindReleasesUsingImageWithKubeConfig(image string, o ListOptions, kubeConfigFile, context string) ([]ReleaseData, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).FindReleasesUsingImage(image, o)
+}
+
+// FindReleasesUsingImage returns the releases with a manifest referencing a container image matching a provided string
+func (c *Client) FindReleasesUsingImage(image string, o ListOptions) ([]ReleaseData, error) {
+	o.IncludeManifest = boolPtr(true)
+	releases, err := c.ListReleases(o)
+	if err != nil {
+		return nil, err
+	}
+	var found []ReleaseData
+	for _, r := range releases {
+		images, err := ReleaseImages(r.Manifest)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse manifest of release %s revision %d: %v", r.Name, r.Revision, err)
+		}
+		for _, i := range images {
+			if strings.Contains(i, image) {
+				found = append(found, r)
+				break
+			}
+		}
+	}
+	return found, nil
+}
+
+// MigrateStorageToSecrets moves releases stored in tiller configmaps to tiller secrets
+func MigrateStorageToSecrets(o ListOptions) error {
+	return MigrateStorageToSecretsWithKubeConfig(o, "", "")
+}
+
+// MigrateStorageToSecretsWithKubeConfig moves releases stored in tiller configmaps to tiller secrets
+func MigrateStorageToSecretsWithKubeConfig(o ListOptions, kubeConfigFile, context string) error {
+	return NewClientWithKubeConfig(kubeConfigFile, context).MigrateStorageToSecrets(o)
+}
+
+// MigrateStorageToSecrets moves releases stored in tiller configmaps to tiller secrets
+func (c *Client) MigrateStorageToSecrets(o ListOptions) error {
+	if o.TillerNamespace == "" {
+		o.TillerNamespace = "kube-system"
+	}
+	if o.TillerLabel == "" {
+		o.TillerLabel = "OWNER=TILLER"
+	}
+	if o.ReleaseName != "" {
+		o.TillerLabel += fmt.Sprintf(",NAME=%s", o.ReleaseName)
+	}
+	configMaps, err := c.ClientSet.CoreV1().ConfigMaps(o.TillerNamespace).List(ctx.Background(), metav1.ListOptions{
+		LabelSelector: o.TillerLabel,
+		FieldSelector: o.FieldSelector,
+	})
+	if err != nil {
+		return wrapAPIError(err)
+	}
+	for _, item := range configMaps.Items {
+		if _, err := DecodeRelease(item.Data["release"]); err != nil {
+			return fmt.Errorf("could not decode release from configmap %s: %v", item.Name, err)
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      item.Name,
+				Namespace: item.Namespace,
+				Labels:    item.Labels,
+			},
+			Data: map[string][]byte{"release": []byte(item.Data["release"])},
+		}
+		_, err := c.ClientSet.CoreV1().Secrets(o.TillerNamespace).Create(ctx.Background(), secret, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			log.Printf("Skipping configmap %s: secret already exists", item.Name)
+			continue
+		}
+		if err != nil {
+			return wrapAPIError(err)
+		}
+		if err := c.ClientSet.CoreV1().ConfigMaps(o.TillerNamespace).Delete(ctx.Background(), item.Name, metav1.DeleteOptions{}); err != nil {
+			return wrapAPIError(err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteReleaseRevisions deletes the tiller resources (configmaps/secrets) of provided release revisions,
+// waiting for limiter (if not nil) before each delete call
+func DeleteReleaseRevisions(name string, revisions []int32, o ListOptions, limiter *rate.Limiter) error {
+	return DeleteReleaseRevisionsWithKubeConfig(name, revisions, o, limiter, "", "")
+}
+
+// DeleteReleaseRevisionsWithKubeConfig deletes the tiller resources (configmaps/secrets) of provided release revisions,
+// waiting for limiter (if not nil) before each delete call
+func DeleteReleaseRevisionsWithKubeConfig(name string, revisions []int32, o ListOptions, limiter *rate.Limiter, kubeConfigFile, context string) error {
+	return NewClientWithKubeConfig(kubeConfigFile, context).DeleteReleaseRevisions(name, revisions, o, limiter)
+}
+
+// DeleteReleaseRevisions deletes the tiller resources (configmaps/secrets) of provided release revisions,
+// waiting for limiter (if not nil) before each delete call
+func (c *Client) DeleteReleaseRevisions(name string, revisions []int32, o ListOptions, limiter *rate.Limiter) error {
+	if o.TillerNamespace == "" {
+		o.TillerNamespace = "kube-system"
+	}
+	storageTypes, err := c.getStorageTypes(o)
+	if err != nil {
+		return err
+	}
+	for _, revision := range revisions {
+		objectName := fmt.Sprintf("%s.v%d", name, revision)
+		deleted := false
+		for _, storage := range storageTypes {
+			if limiter != nil {
+				if err := limiter.Wait(ctx.Background()); err != nil {
+					return err
+				}
+			}
+			switch storage {
+			case "secrets":
+				err = c.ClientSet.CoreV1().Secrets(o.TillerNamespace).Delete(ctx.Background(), objectName, metav1.DeleteOptions{})
+			case "configmaps":
+				err = c.ClientSet.CoreV1().ConfigMaps(o.TillerNamespace).Delete(ctx.Background(), objectName, metav1.DeleteOptions{})
+			}
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("could not delete %s %s: %w", storage, objectName, wrapAPIError(err))
+			}
+			deleted = true
+		}
+		if !deleted {
+			return fmt.Errorf("release %q revision %d: %w", name, revision, ErrNotFound)
+		}
+	}
+	return nil
+}
+
+// PruneHistory deletes the revisions of each release older than its max most recent revisions, never deleting
+// a DEPLOYED revision, and returns the number of deleted revisions. With ListOptions.DryRun, nothing is deleted
+func PruneHistory(o ListOptions, max int) (deleted int, err error) {
+	return PruneHistoryWithKubeConfig(o, max, "", "")
+}
+
+// PruneHistoryWithKubeConfig deletes the revisions of each release older than its max most recent revisions, never deleting
+// a DEPLOYED revision, and returns the number of deleted revisions. With ListOptions.DryRun, nothing is deleted
+func PruneHistoryWithKubeConfig(o ListOptions, max int, kubeConfigFile, context string) (deleted int, err error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).PruneHistory(o, max)
+}
+
+// PruneHistory deletes the revisions of each release older than its max most recent revisions, never deleting
+// a DEPLOYED revision, and returns the number of deleted revisions. With ListOptions.DryRun, nothing is deleted
+func (c *Client) PruneHistory(o ListOptions, max int) (deleted int, err error) {
+	if max < 1 {
+		return 0, fmt.Errorf("at least one revision must be kept, got %d", max)
+	}
+	if o.TillerNamespace == "" {
+		o.TillerNamespace = "kube-system"
+	}
+	// detect the storage type once, for listing and deleting
+	storageTypes, err := c.getStorageTypes(o)
+	if err != nil {
+		return 0, err
+	}
+	o.StorageTypes, o.AllStorageTypes = storageTypes, false
+	o.IncludeManifest = new(bool)
+	releases, err := c.ListReleases(o)
+	if err != nil {
+		return 0, err
+	}
+	history := make(map[string][]ReleaseData)
+	for _, r := range releases {
+		history[r.Name] = append(history[r.Name], r)
+	}
+	var names []string
+	for name := range history {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		revisions := history[name]
+		sort.Slice(revisions, func(i, j int) bool { return revisions[i].Revision > revisions[j].Revision })
+		var toDelete []int32
+		for i, r := range revisions {
+			if i < max || r.Status == rspb.Status_DEPLOYED.String() {
+				continue
+			}
+			toDelete = append(toDelete, r.Revision)
+		}
+		if len(toDelete) == 0 {
+			continue
+		}
+		if !o.DryRun {
+			if err := c.DeleteReleaseRevisions(name, toDelete, o, nil); err != nil {
+				return deleted, err
+			}
+		}
+		deleted += len(toDelete)
+	}
+	return deleted, nil
+}
+
+// ReleaseBackup holds the full revision history of a release, encoded the same way tiller does
+type ReleaseBackup struct {
+	Name      string                  `json:"name"`
+	Revisions []ReleaseBackupRevision `json:"revisions"`
+}
+
+// ReleaseBackupRevision holds a release revision encoded with EncodeRelease
+type ReleaseBackupRevision struct {
+	Revision int32  `json:"revision"`
+	Release  string `json:"release"`
+}
+
+// BackupAllReleases writes the revision history of each release to <destDir>/<name>.json and returns the number of releases backed up
+func BackupAllReleases(destDir string, o ListOptions) (int, error) {
+	return BackupAllReleasesWithKubeConfig(destDir, o, "", "")
+}
+
+// BackupAllReleasesWithKubeConfig writes the revision history of each release to <destDir>/<name>.json and returns the number of releases backed up
+func BackupAllReleasesWithKubeConfig(destDir string, o ListOptions, kubeConfigFile, context string) (int, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).BackupAllReleases(destDir, o)
+}
+
+// BackupAllReleases writes the revision history of each release to <destDir>/<name>.json and returns the number of releases backed up
+func (c *Client) BackupAllReleases(destDir string, o ListOptions) (int, error) {
+	items, err := c.listStorageObjects(o)
+	if err != nil {
+		return 0, err
+	}
+	history := make(map[string][]*rspb.Release)
+	for _, item := range items {
+		rls, err := DecodeRelease(item.itemReleaseData)
+		if err != nil {
+			return 0, fmt.Errorf("could not decode release %s: %v", item.Name, err)
+		}
+		history[rls.Name] = append(history[rls.Name], rls)
+	}
+	var names []string
+	for name := range history {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return 0, err
+	}
+	backedUp := 0
+	var errs []error
+	for _, name := range names {
+		revisions := history[name]
+		sort.Slice(revisions, func(i, j int) bool { return revisions[i].Version < revisions[j].Version })
+		backup := ReleaseBackup{Name: name}
+		if err := func() error {
+			for _, rls := range revisions {
+				encoded, err := EncodeRelease(rls)
+				if err != nil {
+					return err
+				}
+				backup.Revisions = append(backup.Revisions, ReleaseBackupRevision{Revision: rls.Version, Release: encoded})
+			}
+			b, err := json.MarshalIndent(backup, "", "  ")
+			if err != nil {
+				return err
+			}
+			return ioutil.WriteFile(filepath.Join(destDir, name+".json"), b, 0600)
+		}(); err != nil {
+			errs = append(errs, fmt.Errorf("could not back up release %s: %v", name, err))
+			continue
+		}
+		backedUp++
+	}
+	return backedUp, utilerrors.NewAggregate(errs)
+}
+
+const (
+	AnomalyMultipleDeployed = "MULTIPLE_DEPLOYED"
+	AnomalyNoDeployed       = "NO_DEPLOYED"
+	AnomalyRevisionGap      = "REVISION_GAP"
+)
+
+type ReleaseAnomaly struct {
+	Name      string
+	Type      string
+	Revisions []int32
+}
+
+// FindAnomalousReleases returns releases with duplicate deployed revisions, revision gaps or no deployed revision
+func FindAnomalousReleases(o ListOptions) ([]ReleaseAnomaly, error) {
+	return FindAnomalousReleasesWithKubeConfig(o, "", "")
+}
+
+// FindAnomalousReleasesWithKubeConfig returns releases with duplicate deployed revisions, revision gaps or no deployed revision
+func FindAnomalousReleasesWithKubeConfig(o ListOptions, kubeConfigFile, context string) ([]ReleaseAnomaly, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).FindAnomalousReleases(o)
+}
+
+// FindAnomalousReleases returns releases with duplicate deployed revisions, revision gaps or no deployed revision
+func (c *Client) FindAnomalousReleases(o ListOptions) ([]ReleaseAnomaly, error) {
+	releases, err := c.ListReleases(o)
+	if err != nil {
+		return nil, err
+	}
+
+	releasesByName := make(map[string][]ReleaseData)
+	for _, r := range releases {
+		releasesByName[r.Name] = append(releasesByName[r.Name], r)
+	}
+	var names []string
+	for name := range releasesByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var anomalies []ReleaseAnomaly
+	for _, name := range names {
+		revisions := releasesByName[name]
+		sort.Slice(revisions, func(i, j int) bool {
+			return revisions[i].Revision < revisions[j].Revision
+		})
+
+		var deployed, missing []int32
+		for i, r := range revisions {
+			if r.Status == rspb.Status_DEPLOYED.String() {
+				deployed = append(deployed, r.Revision)
+			}
+			if i == 0 {
+				continue
+			}
+			for rev := revisions[i-1].Revision + 1; rev < r.Revision; rev++ {
+				missing = append(missing, rev)
+			}
+		}
+
+		switch {
+		case len(deployed) > 1:
+			anomalies = append(anomalies, ReleaseAnomaly{Name: name, Type: AnomalyMultipleDeployed, Revisions: deployed})
+		case len(deployed) == 0:
+			anomalies = append(anomalies, ReleaseAnomaly{Name: name, Type: AnomalyNoDeployed})
+		}
+		if len(missing) > 0 {
+			anomalies = append(anomalies, ReleaseAnomaly{Name: name, Type: AnomalyRevisionGap, Revisions: missing})
+		}
+	}
+
+	return anomalies, nil
+}
+
+// ClusterReleaseSummaryReport writes a report of the latest revision of all releases grouped by namespace
+func ClusterReleaseSummaryReport(o ListOptions, w io.Writer) error {
+	return ClusterReleaseSummaryReportWithKubeConfig(o, w, "", "")
+}
+
+// ClusterReleaseSummaryReportWithKubeConfig writes a report of the latest revision of all releases grouped by namespace
+func ClusterReleaseSummaryReportWithKubeConfig(o ListOptions, w io.Writer, kubeConfigFile, context string) error {
+	return NewClientWithKubeConfig(kubeConfigFile, context).ClusterReleaseSummaryReport(o, w)
+}
+
+// ClusterReleaseSummaryReport writes a report of the latest revision of all releases grouped by namespace
+func (c *Client) ClusterReleaseSummaryReport(o ListOptions, w io.Writer) error {
+	releases, err := c.ListReleases(o)
+	if err != nil {
+		return err
+	}
+
+	releasesByNamespace := ReleasesByNamespace(getLatestRevisions(releases))
+	var namespaces []string
+	for namespace := range releasesByNamespace {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	var failed []ReleaseData
+	for _, namespace := range namespaces {
+		namespaceReleases := releasesByNamespace[namespace]
+		fmt.Fprintf(tw, "NAMESPACE: %s (%d releases)\n", namespace, len(namespaceReleases))
+		fmt.Fprintln(tw, "NAME\tREVISION\tUPDATED\tSTATUS\tCHART")
+		for _, r := range namespaceReleases {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", r.Name, r.Revision, r.Updated, r.Status, r.Chart)
+			if r.Status == rspb.Status_FAILED.String() {
+				failed = append(failed, r)
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	fmt.Fprintf(tw, "FAILED RELEASES (%d)\n", len(failed))
+	for _, r := range failed {
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", r.Name, r.Namespace, r.Revision)
+	}
+
+	return tw.Flush()
+}
+
+// getLatestRevisions returns the latest revision of each release, sorted by name
+func getLatestRevisions(releases []ReleaseData) []ReleaseData {
+	latest := make(map[string]ReleaseData)
+	for _, r := range releases {
+		if l, ok := latest[r.Name]; !ok || r.Revision > l.Revision {
+			latest[r.Name] = r
+		}
+	}
+	var latestReleases []ReleaseData
+	for _, r := range latest {
+		latestReleases = append(latestReleases, r)
+	}
+	sort.Slice(latestReleases, func(i, j int) bool {
+		return latestReleases[i].Name < latestReleases[j].Name
+	})
+	return latestReleases
+}
+
+// ChartUsage returns the number of releases using each chart version (chart name -> chart version -> count),
+// according to the latest revision of each release
+func ChartUsage(o ListOptions) (map[string]map[string]int, error) {
+	return ChartUsageWithKubeConfig(o, "", "")
+}
+
+// ChartUsageWithKubeConfig returns the number of releases using each chart version (chart name -> chart version -> count),
+// according to the latest revision of each release
+func ChartUsageWithKubeConfig(o ListOptions, kubeConfigFile, context string) (map[string]map[string]int, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).ChartUsage(o)
+}
+
+// ChartUsage returns the number of releases using each chart version (chart name -> chart version -> count),
+// according to the latest revision of each release
+func (c *Client) ChartUsage(o ListOptions) (map[string]map[string]int, error) {
+	releases, err := c.ListReleases(o)
+	if err != nil {
+		return nil, err
+	}
+	usage := make(map[string]map[string]int)
+	for _, r := range getLatestRevisions(releases) {
+		if usage[r.Chart] == nil {
+			usage[r.Chart] = make(map[string]int)
+		}
+		usage[r.Chart][r.ChartVersion]++
+	}
+	return usage, nil
+}
+
+// MostRecentRelease returns the most recently deployed release revision
+func MostRecentRelease(o ListOptions) (*ReleaseData, error) {
+	return MostRecentReleaseWithKubeConfig(o, "", "")
+}
+
+// MostRecentReleaseWithKubeConfig returns the most recently deployed release revision
+func MostRecentReleaseWithKubeConfig(o ListOptions, kubeConfigFile, context string) (*ReleaseData, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).MostRecentRelease(o)
+}
+
+// MostRecentRelease returns the most recently deployed release revision
+func (c *Client) MostRecentRelease(o ListOptions) (*ReleaseData, error) {
+	var mostRecent *ReleaseData
+	err := c.forEachRelease(o, func(releaseData *ReleaseData) bool {
+		if mostRecent == nil || releaseData.Time.After(mostRecent.Time) {
+			mostRecent = releaseData
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if mostRecent == nil {
+		return nil, fmt.Errorf("no releases: %w", ErrNotFound)
+	}
+	return mostRecent, nil
+}
+
+// GetReleasesWithFailedStatus returns the latest revision of each release if its status is FAILED
+func GetReleasesWithFailedStatus(o ListOptions) ([]ReleaseData, error) {
+	return GetReleasesWithFailedStatusWithKubeConfig(o, "", "")
+}
+
+// GetReleasesWithFailedStatusWithKubeConfig returns the latest revision of each release if its status is FAILED
+func GetReleasesWithFailedStatusWithKubeConfig(o ListOptions, kubeConfigFile, context string) ([]ReleaseData, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).GetReleasesWithFailedStatus(o)
+}
+
+// GetReleasesWithFailedStatus returns the latest revision of each release if its status is FAILED
+func (c *Client) GetReleasesWithFailedStatus(o ListOptions) ([]ReleaseData, error) {
+	releases, err := c.ListReleases(o)
+	if err != nil {
+		return nil, err
+	}
+	var failed []ReleaseData
+	for _, r := range getLatestRevisions(releases) {
+		if r.Status == rspb.Status_FAILED.String() {
+			failed = append(failed, r)
+		}
+	}
+	return failed, nil
+}
+
+// ReleasesByNamespace groups releases by namespace, sorting each group by name and revision
+func ReleasesByNamespace(releases []ReleaseData) map[string][]ReleaseData {
+	releasesByNamespace := make(map[string][]ReleaseData)
+	for _, r := range releases {
+		releasesByNamespace[r.Namespace] = append(releasesByNamespace[r.Namespace], r)
+	}
+	for _, namespaceReleases := range releasesByNamespace {
+		sort.SliceStable(namespaceReleases, func(i, j int) bool {
+			if namespaceReleases[i].Name != namespaceReleases[j].Name {
+				return namespaceReleases[i].Name < namespaceReleases[j].Name
+			}
+			return namespaceReleases[i].Revision < namespaceReleases[j].Revision
+		})
+	}
+	return releasesByNamespace
+}
+
+// NamespacesWithReleases returns the sorted namespaces containing releases
+func NamespacesWithReleases(o ListOptions) ([]string, error) {
+	return NamespacesWithReleasesWithKubeConfig(o, "", "")
+}
+
+// NamespacesWithReleasesWithKubeConfig returns the sorted namespaces containing releases
+func NamespacesWithReleasesWithKubeConfig(o ListOptions, kubeConfigFile, context string) ([]string, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).NamespacesWithReleases(o)
+}
+
+// NamespacesWithReleases returns the sorted namespaces containing releases
+func (c *Client) NamespacesWithReleases(o ListOptions) ([]string, error) {
+	o.IncludeManifest = new(bool)
+	releases, err := c.ListReleases(o)
+	if err != nil {
+		return nil, err
+	}
+	uniqNamespaces := make(map[string]string)
+	for _, r := range releases {
+		uniqNamespaces[r.Namespace] = ""
+	}
+	var namespaces []string
+	for namespace := range uniqNamespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+type ListReleaseNamesInNamespaceOptions struct {
+	Namespace       string
+	TillerNamespace string
+}
+
+// ListReleaseNamesInNamespace returns a string list of all releases in a provided namespace
+func ListReleaseNamesInNamespace(o ListReleaseNamesInNamespaceOptions) (string, error) {
+	return ListReleaseNamesInNamespaceWithKubeConfig(o, "", "")
+}
+
+// ListReleaseNamesInNamespaceWithKubeConfig returns a string list of all releases in a provided namespace
+func ListReleaseNamesInNamespaceWithKubeConfig(o ListReleaseNamesInNamespaceOptions, kubeConfigFile, context string) (string, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).ListReleaseNamesInNamespace(o)
+}
+
+// ListReleaseNamesInNamespace returns a string list of all releases in a provided namespace
+func (c *Client) ListReleaseNamesInNamespace(o ListReleaseNamesInNamespaceOptions) (string, error) {
+	releases, err := c.ListReleases(ListOptions{
+		TillerNamespace: o.TillerNamespace,
+	})
+	if err != nil {
+		return "", err
+	}
+	uniqReleases := make(map[string]string)
+	for _, r := range releases {
+		if r.Namespace != o.Namespace {
+			continue
+		}
+		uniqReleases[r.Name] = ""
+	}
+	var names []string
+	for k := range uniqReleases {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ","), nil
+}
+
+// GetAverageDeploymentAge returns the average age of the deployed releases
+func GetAverageDeploymentAge(releases []ReleaseData) time.Duration {
+	now := time.Now()
+	var total time.Duration
+	count := 0
+	for _, r := range releases {
+		if r.Status != rspb.Status_DEPLOYED.String() {
+			continue
+		}
+		if r.Time.IsZero() {
+			log.Printf("Warning: release %s revision %d has no deploy time", r.Name, r.Revision)
+			continue
+		}
+		total += now.Sub(r.Time)
+		count++
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / time.Duration(count)
+}
+
+var releasesCSVHeader = []string{"NAME", "REVISION", "UPDATED", "STATUS", "CHART", "NAMESPACE"}
+
+// MarshalReleasesToCSV writes releases data as CSV (header row followed by one row per release)
+func MarshalReleasesToCSV(releases []ReleaseData, w io.Writer) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(releasesCSVHeader); err != nil {
+		return err
+	}
+	for _, r := range releases {
+		record := []string{
+			r.Name,
+			strconv.Itoa(int(r.Revision)),
+			r.Updated,
+			r.Status,
+			r.Chart,
+			r.Namespace,
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// UnmarshalReleasesFromCSV reads releases data written by MarshalReleasesToCSV
+func UnmarshalReleasesFromCSV(r io.Reader) ([]ReleaseData, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = len(releasesCSVHeader)
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	var releases []ReleaseData
+	for _, record := range records[1:] {
+		revision, err := strconv.ParseInt(record[1], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		// an empty UPDATED column is written for releases without deploy time
+		var deployTime time.Time
+		if record[2] != "" {
+			deployTime, err = time.ParseInLocation(DefaultDeployTimeLayout, record[2], time.Local)
+			if err != nil {
+				return nil, err
+			}
+		}
+		releases = append(releases, ReleaseData{
+			Name:      record[0],
+			Revision:  int32(revision),
+			Updated:   record[2],
+			Status:    record[3],
+			Chart:     record[4],
+			Namespace: record[5],
+			Time:      deployTime,
+		})
+	}
+	return releases, nil
+}
+
+// helmJSONTimeLayout is the layout of the updated field of `helm list -o json`
+const helmJSONTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// helmJSONRelease is a release as printed by `helm list -o json`
+type helmJSONRelease struct {
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	Revision   string `json:"revision"`
+	Updated    string `json:"updated"`
+	Status     string `json:"status"`
+	Chart      string `json:"chart"`
+	AppVersion string `json:"app_version"`
+}
+
+// MarshalHelmJSON returns releases data in the JSON format of `helm list -o json`
+func MarshalHelmJSON(releases []ReleaseData) ([]byte, error) {
+	elements := make([]helmJSONRelease, 0, len(releases))
+	for _, r := range releases {
+		updated := "-"
+		if !r.Time.IsZero() {
+			updated = r.Time.Format(helmJSONTimeLayout)
+		}
+		chart := r.Chart
+		if r.ChartVersion != "" {
+			chart = fmt.Sprintf("%s-%s", r.Chart, r.ChartVersion)
+		}
+		elements = append(elements, helmJSONRelease{
+			Name:       r.Name,
+			Namespace:  r.Namespace,
+			Revision:   strconv.Itoa(int(r.Revision)),
+			Updated:    updated,
+			Status:     strings.ReplaceAll(strings.ToLower(r.Status), "_", "-"),
+			Chart:      chart,
+			AppVersion: r.AppVersion,
+		})
+	}
+	return json.Marshal(elements)
+}
+
+// DefaultDeployTimeLayout is the layout of ReleaseData.Updated
+const DefaultDeployTimeLayout = "Mon Jan _2 15:04:05 2006"
+
+// FormatDeployTime returns the deploy time of a release formatted with a provided layout
+func FormatDeployTime(r ReleaseData, layout string) string {
+	return r.Time.Format(layout)
+}
+
+// GetReleaseData returns a decoded structed release data, or nil if it can not be decoded
+func GetReleaseData(itemReleaseData string) *ReleaseData {
+	releaseData, _ := getReleaseData(itemReleaseData, false)
+	return releaseData
+}
+
+// getReleaseData returns a decoded structed release data, parsing the user-supplied values if includeValues is set
+func getReleaseData(itemReleaseData string, includeValues bool) (*ReleaseData, error) {
+	data, err := DecodeRelease(itemReleaseData)
+	if err != nil {
+		return nil, err
+	}
+	var deployTime time.Time
+	var updated string
+	if lastDeployed := data.GetInfo().GetLastDeployed(); lastDeployed != nil {
+		deployTime = time.Unix(lastDeployed.Seconds, 0)
+		updated = deployTime.Format(DefaultDeployTimeLayout)
+	}
+	chartMeta := data.GetChart().GetMetadata()
+
+	releaseData := ReleaseData{
+		Name:      data.Name,
+		Revision:  data.Version,
+		Updated:   updated,
+		Status:    data.GetInfo().GetStatus().GetCode().String(),
+		Chart:     chartMeta.GetName(),
+		Namespace: data.Namespace,
+		Time:      deployTime,
+		Manifest:  data.Manifest,
+		Notes:     data.GetInfo().GetStatus().GetNotes(),
+		HasTests:  HasTestHooks(data),
+
+		Description: data.GetInfo().GetDescription(),
+
+		ChartVersion:  chartMeta.GetVersion(),
+		AppVersion:    chartMeta.GetAppVersion(),
+		ChartIcon:     chartMeta.GetIcon(),
+		ChartHome:     chartMeta.GetHome(),
+		ChartSources:  chartMeta.GetSources(),
+		ChartKeywords: chartMeta.GetKeywords(),
+
+		Incomplete: data.GetInfo().GetLastDeployed() == nil || chartMeta == nil,
+	}
+	if includeValues {
+		if raw := data.GetConfig().GetRaw(); raw != "" {
+			var values map[string]interface{}
+			if err := yaml.Unmarshal([]byte(raw), &values); err == nil {
+				releaseData.ConfigValues = values
+			}
+		}
+	}
+	return &releaseData, nil
+}
+
+// PendingOperation returns the operation in progress on a release (install, upgrade, rollback or delete),
+// or an empty string if there is none
+func PendingOperation(rd ReleaseData) string {
+	switch rd.Status {
+	case rspb.Status_PENDING_INSTALL.String():
+		return "install"
+	case rspb.Status_PENDING_UPGRADE.String():
+		return "upgrade"
+	case rspb.Status_PENDING_ROLLBACK.String():
+		return "rollback"
+	case rspb.Status_DELETING.String():
+		return "delete"
+	}
+	return ""
+}
+
+// IsPending returns true if an operation is in progress on a release
+func IsPending(rd ReleaseData) bool {
+	return PendingOperation(rd) != ""
+}
+
+// HasTestHooks returns true if a decoded release defines test hooks
+func HasTestHooks(r *rspb.Release) bool {
+	for _, hook := range r.GetHooks() {
+		for _, event := range hook.GetEvents() {
+			if event == rspb.Hook_RELEASE_TEST_SUCCESS || event == rspb.Hook_RELEASE_TEST_FAILURE {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DecodeRelease decodes release data from a tiller resource (configmap/secret)
+func DecodeRelease(data string) (*rspb.Release, error) {
+	rls, _, err := DecodeReleaseRaw(data)
+	return rls, err
+}
+
+// DecodeReleaseRaw decodes release data from a tiller resource (configmap/secret) and returns the decompressed bytes as well
+func DecodeReleaseRaw(data string) (*rspb.Release, []byte, error) {
+	b, err := decodeReleaseBytes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rls rspb.Release
+	// unmarshal json bytes for releases serialized as json
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true}
+		if err := unmarshaler.Unmarshal(bytes.NewReader(b), &rls); err != nil {
+			return nil, b, err
+		}
+		return &rls, b, nil
+	}
+	// unmarshal protobuf bytes
+	if err := proto.Unmarshal(b, &rls); err != nil {
+		return nil, b, err
+	}
+	return &rls, b, nil
+}
+
+// decodeReleaseBytes returns the decompressed protobuf bytes of release data from a tiller resource (configmap/secret)
+func decodeReleaseBytes(data string) ([]byte, error) {
+	// an empty input would otherwise decode without error into an empty release
+	if data == "" {
+		return nil, fmt.Errorf("no release data")
+	}
+	// base64 decode string, treating the input as raw bytes if it is not base64 encoded
+	b, err := base64.StdEncoding.DecodeString(data)
+	if _, ok := err.(base64.CorruptInputError); ok {
+		b = []byte(data)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// For backwards compatibility with releases that were stored before
+	// compression was introduced we skip decompression if the
+	// gzip magic header is not found
+	if len(b) > 3 && bytes.Equal(b[0:3], []byte{0x1f, 0x8b, 0x08}) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		b2, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		b = b2
+	}
+
+	return b, nil
+}
+
+var manifestSeparator = regexp.MustCompile(`(?:^|\s*\n)---\s*`)
+
+// splitManifest splits a release manifest into its yaml documents
+func splitManifest(manifest string) []string {
+	var docs []string
+	for _, doc := range manifestSeparator.Split(manifest, -1) {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// parseManifest parses a release manifest into its kubernetes objects
+func parseManifest(manifest string) ([]unstructured.Unstructured, error) {
+	var objects []unstructured.Unstructured
+	for i, doc := range splitManifest(manifest) {
+		object, err := parseManifestDocument(doc)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %v", i, err)
+		}
+		if object == nil {
+			continue
+		}
+		objects = append(objects, *object)
+	}
+	return objects, nil
+}
+
+// parseManifestDocument parses a single yaml document of a release manifest, returning nil for an empty document
+func parseManifestDocument(doc string) (*unstructured.Unstructured, error) {
+	var object map[string]interface{}
+	if err := yaml.Unmarshal([]byte(doc), &object); err != nil {
+		return nil, err
+	}
+	if object == nil {
+		return nil, nil
+	}
+	return &unstructured.Unstructured{Object: object}, nil
+}
+
+// ValidateManifest returns an error listing the documents of a release manifest which are not valid yaml
+func ValidateManifest(manifest string) error {
+	var errs []error
+	for i, doc := range splitManifest(manifest) {
+		if _, err := parseManifestDocument(doc); err != nil {
+			errs = append(errs, fmt.Errorf("document %d: %v", i, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// GetWorkloadKinds returns the number of resources of each kind in a release manifest, skipping invalid documents
+func GetWorkloadKinds(manifest string) map[string]int {
+	kinds := make(map[string]int)
+	for _, doc := range splitManifest(manifest) {
+		object, err := parseManifestDocument(doc)
+		if err != nil || object == nil || object.GetKind() == "" {
+			continue
+		}
+		kinds[object.GetKind()]++
+	}
+	return kinds
+}
+
+// podSpecPaths are the paths of pod specs in pods, workloads (e.g. deployments, jobs) and cronjobs
+var podSpecPaths = [][]string{
+	{"spec"},
+	{"spec", "template", "spec"},
+	{"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// getContainerImages returns the images of the containers and init containers of an object, keyed by container name
+func getContainerImages(object unstructured.Unstructured) map[string]string {
+	images := make(map[string]string)
+	for _, podSpecPath := range podSpecPaths {
+		for _, field := range []string{"containers", "initContainers"} {
+			containers, _, _ := unstructured.NestedSlice(object.Object, append(podSpecPath, field)...)
+			for _, c := range containers {
+				container, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if image, ok := container["image"].(string); ok && image != "" {
+					name, _ := container["name"].(string)
+					images[name] = image
+				}
+			}
+		}
+	}
+	return images
+}
+
+// ReleaseImages returns the container images referenced by a release manifest
+func ReleaseImages(manifest string) ([]string, error) {
+	objects, err := parseManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	uniqImages := make(map[string]string)
+	for _, object := range objects {
+		for _, image := range getContainerImages(object) {
+			uniqImages[image] = ""
+		}
+	}
+
+	var images []string
+	for image := range uniqImages {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+// HashManifest returns a SHA-256 hex hash of a release manifest, independent of the resources order and formatting.
+// A manifest which can not be parsed is hashed as is.
+func HashManifest(manifest string) string {
+	objects, err := parseManifest(manifest)
+	if err != nil {
+		sum := sha256.Sum256([]byte(manifest))
+		return hex.EncodeToString(sum[:])
+	}
+	sort.SliceStable(objects, func(i, j int) bool {
+		if objects[i].GetKind() != objects[j].GetKind() {
+			return objects[i].GetKind() < objects[j].GetKind()
+		}
+		if objects[i].GetNamespace() != objects[j].GetNamespace() {
+			return objects[i].GetNamespace() < objects[j].GetNamespace()
+		}
+		return objects[i].GetName() < objects[j].GetName()
+	})
+	h := sha256.New()
+	for _, object := range objects {
+		// json encoding of maps sorts the keys, making it canonical
+		b, _ := json.Marshal(object.Object)
+		h.Write(b)
+		h.Write([]byte("\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// GetManifestLabel returns the value of a label from the first resource in a release manifest that has it
+func GetManifestLabel(manifest, label string) (string, error) {
+	objects, err := parseManifest(manifest)
+	if err != nil {
+		return "", err
+	}
+	for _, object := range objects {
+		if value, ok := object.GetLabels()[label]; ok {
+			return value, nil
+		}
+	}
+	return "", nil
+}
+
+// AnnotationsFromRelease returns the chart metadata annotations of a decoded release
+func AnnotationsFromRelease(r *rspb.Release) map[string]string {
+	return r.GetChart().GetMetadata().GetAnnotations()
+}
+
+// EncodeRelease encodes a release the same way tiller does before storing it in a resource (configmap/secret)
+func EncodeRelease(rls *rspb.Release) (string, error) {
+	b, err := proto.Marshal(rls)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err = w.Write(b); err != nil {
+		return "", err
+	}
+	w.Close()
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// EncodeReleaseToConfigMap builds a tiller configmap holding a release
+func EncodeReleaseToConfigMap(rls *rspb.Release, namespace string) (*corev1.ConfigMap, error) {
+	s, err := EncodeRelease(rls)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: newTillerObjectMeta(rls, namespace),
+		Data:       map[string]string{"release": s},
+	}, nil
+}
+
+// EncodeReleaseToSecret builds a tiller secret holding a release
+func EncodeReleaseToSecret(rls *rspb.Release, namespace string) (*corev1.Secret, error) {
+	s, err := EncodeRelease(rls)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.Secret{
+		ObjectMeta: newTillerObjectMeta(rls, namespace),
+		Data:       map[string][]byte{"release": []byte(s)},
+	}, nil
+}
+
+// ErrAlreadyExists is returned when creating a release revision which is already stored
+var ErrAlreadyExists = errors.New("release already exists")
+
+// CreateRelease stores a release in a tiller resource (configmaps/secrets) in a provided namespace
+func CreateRelease(r *rspb.Release, tillerNamespace, storageType string, clientSet *kubernetes.Clientset) error {
+	return NewClient(clientSet).CreateRelease(r, tillerNamespace, storageType)
+}
+
+// CreateRelease stores a release in a tiller resource (configmaps/secrets) in a provided namespace
+func (c *Client) CreateRelease(r *rspb.Release, tillerNamespace, storageType string) error {
+	if tillerNamespace == "" {
+		tillerNamespace = "kube-system"
+	}
+	storage, err := GetStorageFromDriver(storageType)
+	if err != nil {
+		return err
+	}
+	switch storage {
+	case "secrets":
+		secret, encodeErr := EncodeReleaseToSecret(r, tillerNamespace)
+		if encodeErr != nil {
+			return encodeErr
+		}
+		_, err = c.ClientSet.CoreV1().Secrets(tillerNamespace).Create(ctx.Background(), secret, metav1.CreateOptions{})
+	case "configmaps":
+		configMap, encodeErr := EncodeReleaseToConfigMap(r, tillerNamespace)
+		if encodeErr != nil {
+			return encodeErr
+		}
+		_, err = c.ClientSet.CoreV1().ConfigMaps(tillerNamespace).Create(ctx.Background(), configMap, metav1.CreateOptions{})
+	}
+	if apierrors.IsAlreadyExists(err) {
+		return ErrAlreadyExists
+	}
+	return wrapAPIError(err)
+}
+
+// UpdateRelease replaces a release stored in a tiller resource (configmaps/secrets) in a provided namespace
+func UpdateRelease(r *rspb.Release, tillerNamespace, storageType string, clientSet *kubernetes.Clientset) error {
+	return NewClient(clientSet).UpdateRelease(r, tillerNamespace, storageType)
+}
+
+// UpdateRelease replaces a release stored in a tiller resource (configmaps/secrets) in a provided namespace
+func (c *Client) UpdateRelease(r *rspb.Release, tillerNamespace, storageType string) error {
+	if tillerNamespace == "" {
+		tillerNamespace = "kube-system"
+	}
+	storage, err := GetStorageFromDriver(storageType)
+	if err != nil {
+		return err
+	}
+	encoded, err := EncodeRelease(r)
+	if err != nil {
+		return err
+	}
+	objectMeta := newTillerObjectMeta(r, tillerNamespace)
+	switch storage {
+	case "secrets":
+		secret, getErr := c.GetSecretForRelease(r.Name, r.Version, tillerNamespace)
+		if getErr != nil {
+			return getErr
+		}
+		mergeLabels(&secret.ObjectMeta, objectMeta.Labels)
+		secret.Data = map[string][]byte{"release": []byte(encoded)}
+		_, err = c.ClientSet.CoreV1().Secrets(tillerNamespace).Update(ctx.Background(), secret, metav1.UpdateOptions{})
+	case "configmaps":
+		configMap, getErr := c.GetConfigMapForRelease(r.Name, r.Version, tillerNamespace)
+		if getErr != nil {
+			return getErr
+		}
+		mergeLabels(&configMap.ObjectMeta, objectMeta.Labels)
+		configMap.Data = map[string]string{"release": encoded}
+		_, err = c.ClientSet.CoreV1().ConfigMaps(tillerNamespace).Update(ctx.Background(), configMap, metav1.UpdateOptions{})
+	}
+	return wrapAPIError(err)
+}
+
+// mergeLabels sets labels on an object, keeping its other labels
+func mergeLabels(objectMeta *metav1.ObjectMeta, labels map[string]string) {
+	if objectMeta.Labels == nil {
+		objectMeta.Labels = make(map[string]string)
+	}
+	for k, v := range labels {
+		objectMeta.Labels[k] = v
+	}
+}
+
+// releaseNameSuffix matches the generated suffixes stripped by NormalizeReleaseName: a trailing -<hex>
+// (at least 7 characters, e.g. a commit hash) or -<timestamp> (at least 8 digits)
+var releaseNameSuffix = regexp.MustCompile(`-([0-9a-f]{7,}|[0-9]{8,})$`)
+
+// NormalizeReleaseName returns a release name without a trailing -<hex> or -<timestamp> generated suffix
+func NormalizeReleaseName(name string) string {
+	return NormalizeReleaseNameWithPattern(name, releaseNameSuffix)
+}
+
+// NormalizeReleaseNameWithPattern returns a release name without the generated suffix matched by a provided pattern
+func NormalizeReleaseNameWithPattern(name string, re *regexp.Regexp) string {
+	return re.ReplaceAllString(name, "")
+}
+
+// ParseTillerObjectName returns the release name and revision of a tiller resource name (<name>.v<revision>)
+func ParseTillerObjectName(objectName string) (string, int32, error) {
+	i := strings.LastIndex(objectName, ".v")
+	if i <= 0 {
+		return "", 0, fmt.Errorf("invalid tiller object name %q: expected <name>.v<revision>", objectName)
+	}
+	revision, err := strconv.ParseInt(objectName[i+2:], 10, 32)
+	if err != nil || revision < 1 {
+		return "", 0, fmt.Errorf("invalid tiller object name %q: invalid revision %q", objectName, objectName[i+2:])
+	}
+	return objectName[:i], int32(revision), nil
+}
+
+func newTillerObjectMeta(rls *rspb.Release, namespace string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      fmt.Sprintf("%s.v%d", rls.Name, rls.Version),
+		Namespace: namespace,
+		Labels: map[string]string{
+			"NAME":        rls.Name,
+			"OWNER":       "TILLER",
+			"STATUS":      rls.GetInfo().GetStatus().GetCode().String(),
+			"VERSION":     strconv.Itoa(int(rls.Version)),
+			"MODIFIED_AT": strconv.Itoa(int(time.Now().Unix())),
+		},
+	}
+}
+
+// DecodeReleaseFromSecretYAML returns the decoded release data of a tiller secret (or configmap)
+// exported to a file with kubectl get -o yaml. Only tiller (Helm 2) resources can be decoded
+func DecodeReleaseFromSecretYAML(path string) (*ReleaseData, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(b, &typeMeta); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", path, err)
+	}
+	var item storageObject
+	switch typeMeta.Kind {
+	case "Secret":
+		var secret corev1.Secret
+		if err := yaml.Unmarshal(b, &secret); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %v", path, err)
+		}
+		if secret.Type == "helm.sh/release.v1" {
+			return nil, fmt.Errorf("%s is a Helm 3 release: Helm 3 releases are not supported", path)
+		}
+		item = storageObject{secret.ObjectMeta, secretReleaseData(secret.Data, "")}
+	case "ConfigMap":
+		var configMap corev1.ConfigMap
+		if err := yaml.Unmarshal(b, &configMap); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %v", path, err)
+		}
+		item = storageObject{configMap.ObjectMeta, configMapReleaseData(configMap.Data, "")}
+	default:
+		return nil, fmt.Errorf("%s is not a secret or a configmap (kind %q)", path, typeMeta.Kind)
+	}
+	// Helm 3 labels its storage resources with owner=helm, tiller with OWNER=TILLER
+	if item.Labels["owner"] == "helm" {
+		return nil, fmt.Errorf("%s is a Helm 3 release: Helm 3 releases are not supported", path)
+	}
+	if item.itemReleaseData == "" {
+		return nil, fmt.Errorf("%s has no release data", path)
+	}
+	return item.getReleaseData(false)
+}
+
+// DecodeReleases decodes releases data concurrently, preserving the input order in the results.
+// A release that fails to decode is nil in the results and has an error in the returned errors.
+func DecodeReleases(datas []string, workers int) ([]*rspb.Release, []error) {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]*rspb.Release, len(datas))
+	decodeErrs := make([]error, len(datas))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i], decodeErrs[i] = DecodeRelease(datas[i])
+			}
+		}()
+	}
+	for i := range datas {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var errs []error
+	for i, err := range decodeErrs {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("release %d: %v", i, err))
+		}
+	}
+	return results, errs
+}
+
+type ManifestResource struct {
+	APIVersion string
+	Kind       string
+	Name       string
+	Namespace  string
+}
+
+// ReleaseOwnedConfigAndSecrets returns the configmaps and secrets declared in a release manifest
+func ReleaseOwnedConfigAndSecrets(manifest string) ([]ManifestResource, error) {
+	objects, err := parseManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	var resources []ManifestResource
+	for _, object := range objects {
+		if object.GetKind() != "ConfigMap" && object.GetKind() != "Secret" {
+			continue
+		}
+		resources = append(resources, ManifestResource{
+			APIVersion: object.GetAPIVersion(),
+			Kind:       object.GetKind(),
+			Name:       object.GetName(),
+			Namespace:  object.GetNamespace(),
+		})
+	}
+	return resources, nil
+}
+
+type Finding struct {
+	APIVersion string
+	Kind       string
+	Name       string
+	Namespace  string
+	RemovedIn  string
+}
+
+// DeprecatedAPIsInRelease returns the resources of a release manifest using a deprecated api version.
+// removedIn maps an api version ("extensions/v1beta1") or an api version and kind
+// ("extensions/v1beta1/Deployment") to the kubernetes version removing it.
+func DeprecatedAPIsInRelease(manifest string, removedIn map[string]string) ([]Finding, error) {
+	objects, err := parseManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, object := range objects {
+		version, ok := removedIn[object.GetAPIVersion()+"/"+object.GetKind()]
+		if !ok {
+			version, ok = removedIn[object.GetAPIVersion()]
+		}
+		if !ok {
+			continue
+		}
+		findings = append(findings, Finding{
+			APIVersion: object.GetAPIVersion(),
+			Kind:       object.GetKind(),
+			Name:       object.GetName(),
+			Namespace:  object.GetNamespace(),
+			RemovedIn:  version,
+		})
+	}
+	return findings, nil
+}
+
+// GetClientSet returns a kubernetes ClientSet
+func GetClientSet() *kubernetes.Clientset {
+	return GetClientSetWithKubeConfig("", "")
 }
 
-// ListReleases lists all releases according to provided options
-func ListReleases(o ListOptions) ([]ReleaseData, error) {
-	return ListReleasesWithKubeConfig(o, "", "")
+// GetClientSetWithKubeConfig returns a kubernetes ClientSet
+func GetClientSetWithKubeConfig(kubeConfigFile, context string) *kubernetes.Clientset {
+	clientset, _ := getCachedClients(kubeConfigFile, context)
+	return clientset
 }
 
-// ListReleasesWithKubeConfig lists all releases according to provided options
-func ListReleasesWithKubeConfig(o ListOptions, kubeConfigFile, context string) ([]ReleaseData, error) {
-	if o.TillerNamespace == "" {
-		o.TillerNamespace = "kube-system"
+// clientsCacheEntry holds the clients built from a kubeconfig, and the modification times of its files
+type clientsCacheEntry struct {
+	stamp         string
+	clientSet     *kubernetes.Clientset
+	dynamicClient dynamic.Interface
+}
+
+// clientsCache holds the clients built by getCachedClients, keyed by kubeconfig file and context
+var clientsCache = struct {
+	sync.Mutex
+	entries map[string]clientsCacheEntry
+}{entries: make(map[string]clientsCacheEntry)}
+
+// getCachedClients returns a kubernetes ClientSet and a dynamic client built from the same config,
+// reusing the ones previously built for the same kubeconfig file and context if the kubeconfig files did not change
+func getCachedClients(kubeConfigFile, context string) (*kubernetes.Clientset, dynamic.Interface) {
+	key := strings.Join([]string{kubeConfigFile, context, os.Getenv("KUBECONFIG"), os.Getenv("KUBECONFIG_BASE64"), os.Getenv("KUBE_SERVER")}, "\x00")
+	stamp := getKubeConfigStamp(kubeConfigFile)
+
+	clientsCache.Lock()
+	entry, ok := clientsCache.entries[key]
+	clientsCache.Unlock()
+	if ok && entry.stamp == stamp {
+		return entry.clientSet, entry.dynamicClient
 	}
-	if o.TillerLabel == "" {
-		o.TillerLabel = "OWNER=TILLER"
+
+	config := getRestConfigWithKubeConfig(kubeConfigFile, context)
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		log.Fatal(err.Error())
 	}
-	if o.ReleaseName != "" {
-		o.TillerLabel += fmt.Sprintf(",NAME=%s", o.ReleaseName)
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		log.Fatal(err.Error())
 	}
-	clientSet := GetClientSetWithKubeConfig(kubeConfigFile, context)
-	var releasesData []ReleaseData
-	storage := GetTillerStorageWithKubeConfig(o.TillerNamespace, kubeConfigFile, context)
-	switch storage {
-	case "secrets":
-		secrets, err := clientSet.CoreV1().Secrets(o.TillerNamespace).List(ctx.Background(), metav1.ListOptions{
-			LabelSelector: o.TillerLabel,
-		})
-		if err != nil {
-			return nil, err
-		}
-		for _, item := range secrets.Items {
-			releaseData := GetReleaseData((string)(item.Data["release"]))
-			if releaseData == nil {
-				continue
-			}
-			releasesData = append(releasesData, *releaseData)
-		}
-	case "configmaps":
-		configMaps, err := clientSet.CoreV1().ConfigMaps(o.TillerNamespace).List(ctx.Background(), metav1.ListOptions{
-			LabelSelector: o.TillerLabel,
-		})
+
+	clientsCache.Lock()
+	clientsCache.entries[key] = clientsCacheEntry{stamp, clientset, dynamicClient}
+	clientsCache.Unlock()
+	return clientset, dynamicClient
+}
+
+// getKubeConfigStamp returns the modification times of the kubeconfig files loaded for a kubeconfig file
+func getKubeConfigStamp(kubeConfigFile string) string {
+	paths := []string{kubeConfigFile}
+	if kubeConfigFile == "" {
+		paths = clientcmd.NewDefaultClientConfigLoadingRules().GetLoadingPrecedence()
+	}
+	var stamps []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
 		if err != nil {
-			return nil, err
-		}
-		for _, item := range configMaps.Items {
-			releaseData := GetReleaseData(item.Data["release"])
-			if releaseData == nil {
-				continue
-			}
-			releasesData = append(releasesData, *releaseData)
+			stamps = append(stamps, "-")
+			continue
 		}
+		stamps = append(stamps, strconv.FormatInt(info.ModTime().UnixNano(), 10))
 	}
+	return strings.Join(stamps, ",")
+}
 
-	return releasesData, nil
+// GetClientSetWithConnectivityCheck returns a kubernetes ClientSet after verifying the cluster can be reached
+func GetClientSetWithConnectivityCheck(timeout time.Duration) (*kubernetes.Clientset, error) {
+	return GetClientSetWithConnectivityCheckWithKubeConfig(timeout, "", "")
 }
 
-type ListReleaseNamesInNamespaceOptions struct {
-	Namespace       string
-	TillerNamespace string
+// GetClientSetWithConnectivityCheckWithKubeConfig returns a kubernetes ClientSet after verifying the cluster can be reached
+func GetClientSetWithConnectivityCheckWithKubeConfig(timeout time.Duration, kubeConfigFile, context string) (*kubernetes.Clientset, error) {
+	config, err := buildRestConfig(ClientSetOptions{KubeConfigFile: kubeConfigFile, Context: context})
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckConnectivity(clientset, timeout); err != nil {
+		return nil, err
+	}
+	return clientset, nil
 }
 
-// ListReleaseNamesInNamespace returns a string list of all releases in a provided namespace
-func ListReleaseNamesInNamespace(o ListReleaseNamesInNamespaceOptions) (string, error) {
-	return ListReleaseNamesInNamespaceWithKubeConfig(o, "", "")
+// CheckConnectivity verifies the cluster can be reached within the provided timeout
+func CheckConnectivity(clientSet *kubernetes.Clientset, timeout time.Duration) error {
+	c, cancel := ctx.WithTimeout(ctx.Background(), timeout)
+	defer cancel()
+	if err := clientSet.Discovery().RESTClient().Get().AbsPath("/version").Do(c).Error(); err != nil {
+		return fmt.Errorf("cannot reach cluster: %w", wrapAPIError(err))
+	}
+	return nil
 }
 
-// ListReleaseNamesInNamespaceWithKubeConfig returns a string list of all releases in a provided namespace
-func ListReleaseNamesInNamespaceWithKubeConfig(o ListReleaseNamesInNamespaceOptions, kubeConfigFile, context string) (string, error) {
-	releases, err := ListReleasesWithKubeConfig(ListOptions{
-		TillerNamespace: o.TillerNamespace,
-	}, kubeConfigFile, context)
+// GetClientSetAndDynamicClient returns a kubernetes ClientSet and a dynamic client built from the same config
+func GetClientSetAndDynamicClient() (*kubernetes.Clientset, dynamic.Interface) {
+	return GetClientSetAndDynamicClientWithKubeConfig("", "")
+}
+
+// GetClientSetAndDynamicClientWithKubeConfig returns a kubernetes ClientSet and a dynamic client built from the same config
+func GetClientSetAndDynamicClientWithKubeConfig(kubeConfigFile, context string) (*kubernetes.Clientset, dynamic.Interface) {
+	return getCachedClients(kubeConfigFile, context)
+}
+
+// GetClientSetFromBytes returns a kubernetes ClientSet from the content of a kubeconfig file
+func GetClientSetFromBytes(kubeConfig []byte, context string) (*kubernetes.Clientset, error) {
+	config, err := buildConfigFromBytes(kubeConfig, &clientcmd.ConfigOverrides{
+		CurrentContext: context,
+	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	uniqReleases := make(map[string]string)
-	for _, r := range releases {
-		if r.Namespace != o.Namespace {
-			continue
-		}
-		uniqReleases[r.Name] = ""
+	return kubernetes.NewForConfig(config)
+}
+
+// GetClientSetFromKubeconfig returns a kubernetes ClientSet from an in-memory kubeconfig
+func GetClientSetFromKubeconfig(kc *clientcmdapi.Config, context string) (*kubernetes.Clientset, error) {
+	if kc == nil {
+		return nil, fmt.Errorf("a kubeconfig is required")
 	}
-	var inReleases string
-	for k := range uniqReleases {
-		inReleases += k
-		inReleases += ","
+	config, err := buildConfigFromAPIConfig(kc, &clientcmd.ConfigOverrides{
+		CurrentContext: context,
+	})
+	if err != nil {
+		return nil, err
 	}
-	return strings.TrimRight(inReleases, ","), nil
+	return kubernetes.NewForConfig(config)
 }
 
-// GetReleaseData returns a decoded structed release data
-func GetReleaseData(itemReleaseData string) *ReleaseData {
-	data, _ := DecodeRelease(itemReleaseData)
-	deployTime := time.Unix(data.Info.LastDeployed.Seconds, 0)
-	chartMeta := data.GetChart().Metadata
+// GetClientSetWithBearerToken returns a kubernetes ClientSet authenticating to a server with a bearer token,
+// verifying the server with a PEM encoded CA certificate
+func GetClientSetWithBearerToken(server, token, caCert string) (*kubernetes.Clientset, error) {
+	if server == "" || token == "" {
+		return nil, fmt.Errorf("a server and a token are required")
+	}
+	return kubernetes.NewForConfig(&rest.Config{
+		Host:        server,
+		BearerToken: token,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: []byte(caCert),
+		},
+	})
+}
 
-	releaseData := ReleaseData{
-		Name:      data.Name,
-		Revision:  data.Version,
-		Updated:   deployTime.Format("Mon Jan _2 15:04:05 2006"),
-		Status:    data.GetInfo().Status.Code.String(),
-		Chart:     chartMeta.Name,
-		Namespace: data.Namespace,
-		Time:      deployTime,
-		Manifest:  data.Manifest,
+// ClientSetOptions holds the options used to build a kubernetes ClientSet
+type ClientSetOptions struct {
+	KubeConfigFile string
+	Context        string
+	// Overrides are applied on top of the loaded kubeconfig (e.g. cluster server, auth info, namespace)
+	Overrides *clientcmd.ConfigOverrides
+	// Proxy returns the proxy (http, https or socks5) of each request to the cluster, see ProxyFromURL
+	Proxy func(*http.Request) (*url.URL, error)
+}
+
+// GetClientSetWithOptions returns a kubernetes ClientSet according to provided options
+func GetClientSetWithOptions(o ClientSetOptions) (*kubernetes.Clientset, error) {
+	config, err := buildRestConfig(o)
+	if err != nil {
+		return nil, err
 	}
-	return &releaseData
+	return kubernetes.NewForConfig(config)
 }
 
-// DecodeRelease decodes release data from a tiller resource (configmap/secret)
-func DecodeRelease(data string) (*rspb.Release, error) {
-	// base64 decode string
-	b, err := base64.StdEncoding.DecodeString(data)
+// GetClientSetWithProxy returns a kubernetes ClientSet sending requests through a proxy, respecting NO_PROXY
+func GetClientSetWithProxy(proxyURL string) (*kubernetes.Clientset, error) {
+	proxy, err := ProxyFromURL(proxyURL)
 	if err != nil {
 		return nil, err
 	}
+	return GetClientSetWithOptions(ClientSetOptions{Proxy: proxy})
+}
 
-	// For backwards compatibility with releases that were stored before
-	// compression was introduced we skip decompression if the
-	// gzip magic header is not found
-	if bytes.Equal(b[0:3], []byte{0x1f, 0x8b, 0x08}) {
-		r, err := gzip.NewReader(bytes.NewReader(b))
-		if err != nil {
-			return nil, err
+// GetClientSetWithTransport returns a kubernetes ClientSet sending requests through a provided transport.
+// A transport returned by ConfigureRetryTransport wraps the transport built from the kubeconfig (TLS, proxy),
+// any other transport replaces it
+func GetClientSetWithTransport(transport http.RoundTripper) (*kubernetes.Clientset, error) {
+	config, err := buildRestConfig(ClientSetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if t, ok := transport.(*retryTransport); ok {
+			return t.wrap(rt)
 		}
-		b2, err := ioutil.ReadAll(r)
-		if err != nil {
-			return nil, err
+		return transport
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// retryBaseDelay is the delay before the first retry of retryTransport, doubled on each retry
+const retryBaseDelay = 200 * time.Millisecond
+
+// retryTransport retries GET requests on network errors and optionally on 429 Too Many Requests
+type retryTransport struct {
+	next       http.RoundTripper
+	maxRetries int
+	retryOn429 bool
+}
+
+// ConfigureRetryTransport returns a transport retrying GET requests up to maxRetries times with exponential backoff
+// on network errors, and on 429 Too Many Requests if retryOn429 is set
+func ConfigureRetryTransport(maxRetries int, retryOn429 bool) http.RoundTripper {
+	return &retryTransport{
+		next:       http.DefaultTransport,
+		maxRetries: maxRetries,
+		retryOn429: retryOn429,
+	}
+}
+
+// wrap returns a copy of the transport sending requests through next
+func (t *retryTransport) wrap(next http.RoundTripper) http.RoundTripper {
+	wrapped := *t
+	wrapped.next = next
+	return &wrapped
+}
+
+// RoundTrip sends a request, retrying GET requests on network errors and optionally on 429 Too Many Requests
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.next.RoundTrip(req)
+	}
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		retry := err != nil || (t.retryOn429 && resp.StatusCode == http.StatusTooManyRequests)
+		if !retry || attempt >= t.maxRetries {
+			return resp, err
 		}
-		b = b2
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
 	}
+}
 
-	var rls rspb.Release
-	// unmarshal protobuf bytes
-	if err := proto.Unmarshal(b, &rls); err != nil {
+// ProxyFromURL returns a proxy function sending requests through a proxy (e.g. http://host:3128, socks5://host:1080), respecting NO_PROXY
+func ProxyFromURL(proxyURL string) (func(*http.Request) (*url.URL, error), error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
 		return nil, err
 	}
-	return &rls, nil
+	proxyFunc := (&httpproxy.Config{
+		HTTPProxy:  u.String(),
+		HTTPSProxy: u.String(),
+		NoProxy:    getEnvAny("NO_PROXY", "no_proxy"),
+	}).ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}, nil
 }
 
-// GetClientSet returns a kubernetes ClientSet
-func GetClientSet() *kubernetes.Clientset {
-	return GetClientSetWithKubeConfig("", "")
+// getEnvAny returns the value of the first set environment variable
+func getEnvAny(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
 }
 
-// GetClientSetWithKubeConfig returns a kubernetes ClientSet
-func GetClientSetWithKubeConfig(kubeConfigFile, context string) *kubernetes.Clientset {
-	var kubeConfigFiles []string
-	if kubeConfigFile != "" {
-		kubeConfigFiles = append(kubeConfigFiles, kubeConfigFile)
-	} else if kubeConfigPath := os.Getenv("KUBECONFIG"); kubeConfigPath != "" {
-		// The KUBECONFIG environment variable holds a list of kubeconfig files.
-		// For Linux and Mac, the list is colon-delimited. For Windows, the list
-		// is semicolon-delimited. Ref:
-		// https://kubernetes.io/docs/concepts/configuration/organize-cluster-access-kubeconfig/#the-kubeconfig-environment-variable
-		var separator string
-		if runtime.GOOS == "windows" {
-			separator = ";"
-		} else {
-			separator = ":"
-		}
-		kubeConfigFiles = strings.Split(kubeConfigPath, separator)
-	} else {
-		kubeConfigFiles = append(kubeConfigFiles, filepath.Join(os.Getenv("HOME"), ".kube", "config"))
+func getRestConfigWithKubeConfig(kubeConfigFile, context string) *rest.Config {
+	config, err := buildRestConfig(ClientSetOptions{
+		KubeConfigFile: kubeConfigFile,
+		Context:        context,
+	})
+	if err != nil {
+		log.Fatal(err.Error())
 	}
 
-	config, err := buildConfigFromFlags(context, kubeConfigFiles)
+	return config
+}
+
+func buildRestConfig(o ClientSetOptions) (*rest.Config, error) {
+	config, err := loadRestConfig(o)
 	if err != nil {
-		log.Fatal(err.Error())
+		return nil, err
 	}
+	if o.Proxy != nil {
+		config.Proxy = o.Proxy
+	}
+	return config, nil
+}
 
-	clientset, err := kubernetes.NewForConfig(config)
+func loadRestConfig(o ClientSetOptions) (*rest.Config, error) {
+	overrides := &clientcmd.ConfigOverrides{}
+	if o.Overrides != nil {
+		*overrides = *o.Overrides
+	}
+	if o.Context != "" {
+		overrides.CurrentContext = o.Context
+	}
+
+	if kubeConfigBase64 := os.Getenv("KUBECONFIG_BASE64"); o.KubeConfigFile == "" && kubeConfigBase64 != "" {
+		kubeConfig, err := base64.StdEncoding.DecodeString(kubeConfigBase64)
+		if err != nil {
+			return nil, err
+		}
+		return buildConfigFromBytes(kubeConfig, overrides)
+	}
+
+	// The default loading rules merge the kubeconfig files listed in the KUBECONFIG
+	// environment variable (falling back to ~/.kube/config) the same way kubectl does
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if o.KubeConfigFile != "" {
+		loadingRules.ExplicitPath = o.KubeConfigFile
+	}
+
+	config, err := buildConfigFromFlags(loadingRules, overrides)
+	if err != nil && o.KubeConfigFile == "" && os.Getenv("KUBE_SERVER") != "" {
+		// fall back to client certificate files provided by environment variables
+		return buildConfigFromCertEnv()
+	}
+	return config, err
+}
+
+// buildConfigFromCertEnv builds a config from the KUBE_SERVER, KUBE_CERT, KUBE_KEY and KUBE_CA environment variables
+func buildConfigFromCertEnv() (*rest.Config, error) {
+	certFile, keyFile := os.Getenv("KUBE_CERT"), os.Getenv("KUBE_KEY")
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("KUBE_CERT and KUBE_KEY must be set when using KUBE_SERVER")
+	}
+	return &rest.Config{
+		Host: os.Getenv("KUBE_SERVER"),
+		TLSClientConfig: rest.TLSClientConfig{
+			CertFile: certFile,
+			KeyFile:  keyFile,
+			CAFile:   os.Getenv("KUBE_CA"),
+		},
+	}, nil
+}
+
+func buildConfigFromBytes(kubeConfig []byte, overrides *clientcmd.ConfigOverrides) (*rest.Config, error) {
+	apiConfig, err := clientcmd.Load(kubeConfig)
 	if err != nil {
-		log.Fatal(err.Error())
+		return nil, err
 	}
+	return buildConfigFromAPIConfig(apiConfig, overrides)
+}
 
-	return clientset
+func buildConfigFromAPIConfig(apiConfig *clientcmdapi.Config, overrides *clientcmd.ConfigOverrides) (*rest.Config, error) {
+	return clientcmd.NewNonInteractiveClientConfig(
+		*apiConfig,
+		overrides.CurrentContext,
+		overrides, nil).ClientConfig()
 }
 
-func buildConfigFromFlags(context string, kubeConfigFiles []string) (*rest.Config, error) {
+// buildConfigFromFlags builds a config from the kubeconfig files. The overrides are merged on top of
+// the selected context, so exec credential plugins (e.g. aws-iam-authenticator, gke-gcloud-auth-plugin)
+// configured for its user are kept in the resulting config.
+func buildConfigFromFlags(loadingRules *clientcmd.ClientConfigLoadingRules, overrides *clientcmd.ConfigOverrides) (*rest.Config, error) {
 	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		&clientcmd.ClientConfigLoadingRules{Precedence: kubeConfigFiles},
-		&clientcmd.ConfigOverrides{
-			CurrentContext: context,
-		}).ClientConfig()
+		loadingRules,
+		overrides).ClientConfig()
+}
+
+// GetStorageFromDriver returns the storage type (configmaps/secrets) matching a storage driver name
+func GetStorageFromDriver(driver string) (string, error) {
+	switch strings.ToLower(driver) {
+	case "secret", "secrets":
+		return "secrets", nil
+	case "configmap", "configmaps":
+		return "configmaps", nil
+	case "memory", "sql":
+		return "", fmt.Errorf("storage driver %q can not be read from the cluster", driver)
+	}
+	return "", fmt.Errorf("unknown storage driver %q", driver)
+}
+
+func (c *Client) getStorageTypes(o ListOptions) ([]string, error) {
+	if o.AllStorageTypes {
+		return []string{"secrets", "configmaps"}, nil
+	}
+	if len(o.StorageTypes) > 0 {
+		var storageTypes []string
+		for _, storageType := range o.StorageTypes {
+			storage, err := GetStorageFromDriver(storageType)
+			if err != nil {
+				return nil, err
+			}
+			storageTypes = append(storageTypes, storage)
+		}
+		return storageTypes, nil
+	}
+	if o.StorageDriver != "" {
+		storage, err := GetStorageFromDriver(o.StorageDriver)
+		if err != nil {
+			return nil, err
+		}
+		return []string{storage}, nil
+	}
+	storage, err := c.GetTillerStorage(o.TillerNamespace)
+	if err != nil {
+		return nil, err
+	}
+	return []string{storage}, nil
 }
 
 // GetTillerStorage returns the storage type of tiller (configmaps/secrets)
@@ -233,24 +3427,33 @@ func GetTillerStorage(tillerNamespace string) string {
 	return GetTillerStorageWithKubeConfig(tillerNamespace, "", "")
 }
 
+// GetTillerStorageForContext returns the storage type of tiller (configmaps/secrets) using a specific kubeconfig context
+func GetTillerStorageForContext(context, tillerNamespace string) string {
+	return GetTillerStorageWithKubeConfig(tillerNamespace, "", context)
+}
+
 // GetTillerStorageWithKubeConfig returns the storage type of tiller (configmaps/secrets)
 func GetTillerStorageWithKubeConfig(tillerNamespace, kubeConfigFile, context string) string {
-	clientset := GetClientSetWithKubeConfig(kubeConfigFile, context)
-	coreV1 := clientset.CoreV1()
-	listOptions := metav1.ListOptions{
-		LabelSelector: "name=tiller",
-	}
-	pods, err := coreV1.Pods(tillerNamespace).List(ctx.Background(), listOptions)
+	storage, err := NewClientWithKubeConfig(kubeConfigFile, context).GetTillerStorage(tillerNamespace)
 	if err != nil {
 		log.Fatal(err)
 	}
+	return storage
+}
+
+// GetTillerStorage returns the storage type of tiller (configmaps/secrets)
+func (c *Client) GetTillerStorage(tillerNamespace string) (string, error) {
+	pods, err := c.GetTillerPods(tillerNamespace, "")
+	if err != nil {
+		return "", err
+	}
 
-	if len(pods.Items) == 0 {
-		log.Fatal("Found 0 tiller pods")
+	if len(pods) == 0 {
+		return "", fmt.Errorf("Found 0 tiller pods")
 	}
 
 	storage := "configmaps"
-	container := pods.Items[0].Spec.Containers[0]
+	container := pods[0].Spec.Containers[0]
 	for _, c := range container.Command {
 		if strings.Contains(c, "secret") {
 			storage = "secrets"
@@ -262,7 +3465,95 @@ func GetTillerStorageWithKubeConfig(tillerNamespace, kubeConfigFile, context str
 		}
 	}
 
-	return storage
+	return storage, nil
+}
+
+// GetTillerStorageAuto returns the storage type of tiller (configmaps/secrets) of each provided tiller namespace,
+// detected in parallel. Namespaces where the storage type can not be detected are omitted
+func GetTillerStorageAuto(tillerNamespaces []string) map[string]string {
+	return GetTillerStorageAutoWithKubeConfig(tillerNamespaces, "", "")
+}
+
+// GetTillerStorageAutoWithKubeConfig returns the storage type of tiller (configmaps/secrets) of each provided tiller namespace,
+// detected in parallel. Namespaces where the storage type can not be detected are omitted
+func GetTillerStorageAutoWithKubeConfig(tillerNamespaces []string, kubeConfigFile, context string) map[string]string {
+	return NewClientWithKubeConfig(kubeConfigFile, context).GetTillerStorageAuto(tillerNamespaces)
+}
+
+// GetTillerStorageAuto returns the storage type of tiller (configmaps/secrets) of each provided tiller namespace,
+// detected in parallel. Namespaces where the storage type can not be detected are omitted
+func (c *Client) GetTillerStorageAuto(tillerNamespaces []string) map[string]string {
+	storages := make(map[string]string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, tillerNamespace := range tillerNamespaces {
+		wg.Add(1)
+		go func(tillerNamespace string) {
+			defer wg.Done()
+			storage, err := c.GetTillerStorage(tillerNamespace)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			storages[tillerNamespace] = storage
+			mu.Unlock()
+		}(tillerNamespace)
+	}
+	wg.Wait()
+	return storages
+}
+
+// GetTillerPods returns the tiller pods matching a label selector in a provided namespace
+func GetTillerPods(namespace, label string) ([]corev1.Pod, error) {
+	return GetTillerPodsWithKubeConfig(namespace, label, "", "")
+}
+
+// GetTillerPodsWithKubeConfig returns the tiller pods matching a label selector in a provided namespace
+func GetTillerPodsWithKubeConfig(namespace, label, kubeConfigFile, context string) ([]corev1.Pod, error) {
+	return NewClientWithKubeConfig(kubeConfigFile, context).GetTillerPods(namespace, label)
+}
+
+// GetTillerPods returns the tiller pods matching a label selector in a provided namespace
+func (c *Client) GetTillerPods(namespace, label string) ([]corev1.Pod, error) {
+	if label == "" {
+		label = getTillerPodLabels()
+	}
+	pods, err := c.ClientSet.CoreV1().Pods(namespace).List(ctx.Background(), metav1.ListOptions{
+		LabelSelector: label,
+	})
+	if err != nil {
+		return nil, wrapAPIError(err)
+	}
+	return pods.Items, nil
+}
+
+// getTillerPodLabels returns the tiller pods label selector from TILLER_LABELS, defaulting to name=tiller
+func getTillerPodLabels() string {
+	if labels := os.Getenv("TILLER_LABELS"); labels != "" {
+		return labels
+	}
+	return "name=tiller"
+}
+
+// GetHelmBinaryPath returns the path of the helm executable
+func GetHelmBinaryPath() (string, error) {
+	if helmBin := os.Getenv("HELM_BIN"); helmBin != "" {
+		if path, err := exec.LookPath(helmBin); err == nil {
+			return path, nil
+		}
+	}
+	if path, err := exec.LookPath("helm"); err == nil {
+		return path, nil
+	}
+	for _, path := range []string{
+		"/usr/local/bin/helm",
+		filepath.Join(os.Getenv("HOME"), ".helm", "bin", "helm"),
+	} {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("helm executable not found")
 }
 
 // Execute executes a command
@@ -281,6 +3572,58 @@ func Execute(cmd []string) []byte {
 	return output
 }
 
+// ExecuteInDir executes a command in a provided working directory and returns the output.
+// A relative command path (e.g. ./bin/helm) is resolved against dir
+func ExecuteInDir(dir string, cmd []string) ([]byte, error) {
+	binary := cmd[0]
+	if filepath.Base(binary) == binary {
+		if _, err := exec.LookPath(binary); err != nil {
+			return nil, err
+		}
+	}
+
+	command := exec.Command(binary, cmd[1:]...)
+	command.Dir = dir
+	return command.Output()
+}
+
+// ErrOutputLimitExceeded is returned when a command output exceeds the provided limit
+var ErrOutputLimitExceeded = errors.New("command output limit exceeded")
+
+// ExecuteWithLimit executes a command and returns at most maxBytes of its output
+func ExecuteWithLimit(cmd []string, maxBytes int64) ([]byte, error) {
+	binary := cmd[0]
+	if _, err := exec.LookPath(binary); err != nil {
+		return nil, err
+	}
+
+	command := exec.Command(binary, cmd[1:]...)
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := command.Start(); err != nil {
+		return nil, err
+	}
+
+	output, err := ioutil.ReadAll(io.LimitReader(stdout, maxBytes+1))
+	if err != nil {
+		command.Process.Kill()
+		command.Wait()
+		return nil, err
+	}
+	if int64(len(output)) > maxBytes {
+		command.Process.Kill()
+		command.Wait()
+		return output[:maxBytes], ErrOutputLimitExceeded
+	}
+	if err := command.Wait(); err != nil {
+		return output, fmt.Errorf("command execution failed: %v: %v", cmd, err)
+	}
+
+	return output, nil
+}
+
 // ExecuteCombined executes a command and resturns the combined output
 func ExecuteCombined(cmd []string) []byte {
 	binary := cmd[0]
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const execAuthKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: default
+  cluster:
+    server: https://default.example.com
+- name: eks
+  cluster:
+    server: https://eks.example.com
+users:
+- name: default
+  user:
+    token: default-token
+- name: eks
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws-iam-authenticator
+      args:
+      - token
+      - -i
+      - my-cluster
+contexts:
+- name: default
+  context:
+    cluster: default
+    user: default
+- name: eks
+  context:
+    cluster: eks
+    user: eks
+current-context: default
+`
+
+func assertExecProvider(t *testing.T, config *rest.Config) {
+	t.Helper()
+	if config.Host != "https://eks.example.com" {
+		t.Errorf("expected host of the eks context, got %q", config.Host)
+	}
+	if config.ExecProvider == nil {
+		t.Fatal("expected an exec provider for the eks user")
+	}
+	if config.ExecProvider.Command != "aws-iam-authenticator" {
+		t.Errorf("expected command aws-iam-authenticator, got %q", config.ExecProvider.Command)
+	}
+	if args := []string{"token", "-i", "my-cluster"}; !reflect.DeepEqual(config.ExecProvider.Args, args) {
+		t.Errorf("expected args %v, got %v", args, config.ExecProvider.Args)
+	}
+}
+
+func TestBuildConfigFromBytesExecAuth(t *testing.T) {
+	config, err := buildConfigFromBytes([]byte(execAuthKubeConfig), &clientcmd.ConfigOverrides{CurrentContext: "eks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertExecProvider(t, config)
+}
+
+func TestLoadRestConfigExecAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	kubeConfigFile := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(kubeConfigFile, []byte(execAuthKubeConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadRestConfig(ClientSetOptions{KubeConfigFile: kubeConfigFile, Context: "eks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertExecProvider(t, config)
+}